@@ -0,0 +1,209 @@
+// Package drivedb reads drive database of smartmontools (drivedb.h) to find
+// vendor specific names and raw value formats of ATA SMART attributes of a
+// drive model.
+package drivedb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Entry is an entry of drive database.
+type Entry struct {
+	ModelFamily string
+	// ModelRegexp and FirmwareRegexp are POSIX extended regular
+	// expressions which must match the whole model name and firmware
+	// version. An empty FirmwareRegexp matches any firmware.
+	ModelRegexp    string
+	FirmwareRegexp string
+	WarningMsg     string
+	// Presets are smartctl options applied to matching drives, e.g.
+	// "-v 9,minutes -v 194,tempminmax".
+	Presets string
+
+	model, firmware *regexp.Regexp
+}
+
+// Attribute is a preset of an ATA SMART attribute set with -v option of
+// smartctl: -v ID,FORMAT[:BYTEORDER][,NAME[,HDD|SSD]].
+type Attribute struct {
+	ID int
+	// Format is a raw value format, e.g. raw48 or tempminmax.
+	Format    string
+	ByteOrder string
+	// Name is empty if the preset does not rename the attribute.
+	Name string
+	// DriveType is HDD or SSD if the preset only applies to one type of
+	// drives, empty otherwise.
+	DriveType string
+}
+
+// DB is a drive database.
+type DB struct {
+	// Default is the entry with presets applied to all drives.
+	Default *Entry
+	Entries []*Entry
+}
+
+// Load reads drive database from a file.
+func Load(path string) (*DB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	db, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return db, nil
+}
+
+// Parse reads drive database in the format of drivedb.h of smartmontools.
+// Entries with regular expressions which cannot be compiled are skipped, as
+// well as special entries for USB bridges and the database version.
+func Parse(r io.Reader) (*DB, error) {
+	toks, err := tokenize(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+	db := &DB{}
+	for i := 0; i < len(toks); i++ {
+		fields, n := entryFields(toks[i:])
+		if fields == nil {
+			continue
+		}
+		i += n - 1
+		e := &Entry{
+			ModelFamily:    fields[0],
+			ModelRegexp:    fields[1],
+			FirmwareRegexp: fields[2],
+			WarningMsg:     fields[3],
+			Presets:        fields[4],
+		}
+		switch {
+		case e.ModelFamily == "DEFAULT":
+			db.Default = e
+			continue
+		case strings.HasPrefix(e.ModelFamily, "VERSION:"), strings.HasPrefix(e.ModelFamily, "USB:"):
+			continue
+		}
+		if e.model, err = compile(e.ModelRegexp); err != nil {
+			continue
+		}
+		if e.FirmwareRegexp != "" {
+			if e.firmware, err = compile(e.FirmwareRegexp); err != nil {
+				continue
+			}
+		}
+		db.Entries = append(db.Entries, e)
+	}
+	if len(db.Entries) == 0 && db.Default == nil {
+		return nil, fmt.Errorf("drivedb: no entries found")
+	}
+	return db, nil
+}
+
+func compile(re string) (*regexp.Regexp, error) {
+	// Expressions must match whole strings, as in smartctl.
+	return regexp.Compile("^(?:" + re + ")$")
+}
+
+// Lookup returns the first entry matching model and firmware or nil.
+func (db *DB) Lookup(model, firmware string) *Entry {
+	if db == nil {
+		return nil
+	}
+	for _, e := range db.Entries {
+		if e.model.MatchString(model) && (e.firmware == nil || e.firmware.MatchString(firmware)) {
+			return e
+		}
+	}
+	return nil
+}
+
+// Attributes returns attribute presets for a drive by attribute ID. Presets
+// of the default entry are overridden by presets of the entry matching model
+// and firmware. Presets for HDD or SSD only are skipped unless driveType is
+// the same, so the preset for all drive types applies instead. driveType is
+// empty if the type of the drive is unknown. A nil DB has no presets.
+func (db *DB) Attributes(model, firmware, driveType string) map[int]Attribute {
+	if db == nil {
+		return nil
+	}
+	attrs := map[int]Attribute{}
+	for _, e := range []*Entry{db.Default, db.Lookup(model, firmware)} {
+		if e == nil {
+			continue
+		}
+		// Presets for the drive type take precedence over presets
+		// for all drive types of the same entry.
+		for _, typed := range []bool{false, true} {
+			for _, a := range e.Attributes() {
+				if (a.DriveType != "") != typed || typed && a.DriveType != driveType {
+					continue
+				}
+				if a.Name == "" {
+					// smartctl keeps the default name.
+					a.Name = attrs[a.ID].Name
+				}
+				attrs[a.ID] = a
+			}
+		}
+	}
+	return attrs
+}
+
+// legacyPresets are old style -v arguments which smartctl still accepts,
+// with their modern equivalents.
+var legacyPresets = map[string]string{
+	"9,minutes":      "9,min2hour,Power_On_Minutes",
+	"9,seconds":      "9,sec2hour,Power_On_Seconds",
+	"9,halfminutes":  "9,halfmin2hour,Power_On_Half_Minutes",
+	"9,temp":         "9,tempminmax,Temperature_Celsius",
+	"194,10xCelsius": "194,temp10x,Temperature_Celsius_x10",
+	"194,unknown":    "194,raw48,Unknown_Attribute",
+}
+
+// Attributes returns attribute presets set with -v options in Presets.
+// Presets for all attributes at once (-v N,FORMAT) and malformed ones are
+// ignored.
+func (e *Entry) Attributes() []Attribute {
+	var attrs []Attribute
+	f := strings.Fields(e.Presets)
+	for i := 0; i+1 < len(f); i++ {
+		if f[i] != "-v" {
+			continue
+		}
+		i++
+		v := f[i]
+		if l, ok := legacyPresets[v]; ok {
+			v = l
+		}
+		p := strings.Split(v, ",")
+		if len(p) < 2 {
+			continue
+		}
+		id, err := strconv.Atoi(p[0])
+		if err != nil || id < 1 || id > 255 {
+			continue
+		}
+		a := Attribute{ID: id, Format: p[1]}
+		if i := strings.IndexByte(a.Format, ':'); i >= 0 {
+			a.Format, a.ByteOrder = a.Format[:i], a.Format[i+1:]
+		}
+		if len(p) > 2 {
+			a.Name = p[2]
+		}
+		if len(p) > 3 {
+			a.DriveType = p[3]
+		}
+		attrs = append(attrs, a)
+	}
+	return attrs
+}
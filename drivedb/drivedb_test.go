@@ -0,0 +1,166 @@
+package drivedb
+
+import (
+	"strings"
+	"testing"
+)
+
+const testDB = `/*
+ * drivedb.h - smartmontools drive database file
+ */
+
+/*
+const drive_settings builtin_knowndrives[] = {
+ */
+  { "VERSION: 7.0/4842 2018-12-02 16:07:26",
+    "-", "-",
+    "This is a dummy entry to hold the SVN-Id of drivedb.h",
+    ""
+  /* Default settings:
+    "-v 1,raw48,Raw_Read_Error_Rate "
+  */
+  },
+  { "DEFAULT",
+    "-", "-",
+    "Default settings",
+    "-v 1,raw48,Raw_Read_Error_Rate "
+    "-v 9,raw24(raw8),Power_On_Hours "
+    "-v 194,tempminmax,Temperature_Celsius "
+    "-v 231,raw48,Temperature_Celsius,HDD "
+    "-v 231,raw48,SSD_Life_Left,SSD "
+    "-v 232,raw48,Available_Reservd_Space,SSD "
+    "-v 232,raw48,Unknown_Attribute"
+  },
+#ifdef SMARTMONTOOLS_BUILD
+  { "Seagate Barracuda 7200.14 (AF)", // tested with ST1000DM003-9YN162/CC46
+    "ST(1000|1500|2000|2500|3000)DM00[1-3]-9YN16.|"
+    "ST(2000|3000)DM001-9YN164",
+    "", "",
+    "-v 188,raw16 -v 240,msec24hour32"
+  },
+#endif
+  { "Western Digital Red", /* tested with WDC WD20EFRX-68AX9N0/80.00A80 */
+    "WDC WD(7500BFCX|[1-6]0EFRX)-.*",
+    "80\\.00A80|82\\.00A82",
+    "",
+    "-v 9,minutes -v 16,raw48,Total_LBAs_Read_\"x\",SSD -v 194,raw48"
+  },
+  { "USB: ; ",
+    "0x0402:0x5621",
+    "",
+    "",
+    ""
+  },
+  { "Broken", "[", "", "", "" },
+/*
+};
+ */
+`
+
+func TestParse(t *testing.T) {
+	db, err := Parse(strings.NewReader(testDB))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Default == nil || db.Default.WarningMsg != "Default settings" {
+		t.Errorf("Default = %+v, want entry with Default settings", db.Default)
+	}
+	var families []string
+	for _, e := range db.Entries {
+		families = append(families, e.ModelFamily)
+	}
+	want := []string{"Seagate Barracuda 7200.14 (AF)", "Western Digital Red"}
+	if strings.Join(families, "|") != strings.Join(want, "|") {
+		t.Errorf("Entries have families %q, want %q", families, want)
+	}
+	if len(db.Entries) == 2 {
+		if got, want := db.Entries[0].ModelRegexp, "ST(1000|1500|2000|2500|3000)DM00[1-3]-9YN16.|ST(2000|3000)DM001-9YN164"; got != want {
+			t.Errorf("ModelRegexp = %q, want %q", got, want)
+		}
+		if got, want := db.Entries[1].FirmwareRegexp, `80\.00A80|82\.00A82`; got != want {
+			t.Errorf("FirmwareRegexp = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	if _, err := Parse(strings.NewReader("/* nothing */\n")); err == nil {
+		t.Error("Parse() of a file without entries succeeded")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	db, err := Parse(strings.NewReader(testDB))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		model, firmware string
+		family          string
+	}{
+		{"ST1000DM003-9YN162", "CC46", "Seagate Barracuda 7200.14 (AF)"},
+		{"ST3000DM001-9YN164", "", "Seagate Barracuda 7200.14 (AF)"},
+		// Regular expressions must match the whole model name.
+		{"XST1000DM003-9YN162", "CC46", ""},
+		{"WDC WD20EFRX-68AX9N0", "80.00A80", "Western Digital Red"},
+		{"WDC WD20EFRX-68AX9N0", "80.00A81", ""},
+		{"0x0402:0x5621", "", ""},
+	} {
+		family := ""
+		if e := db.Lookup(tc.model, tc.firmware); e != nil {
+			family = e.ModelFamily
+		}
+		if family != tc.family {
+			t.Errorf("Lookup(%q, %q) found %q, want %q", tc.model, tc.firmware, family, tc.family)
+		}
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	db, err := Parse(strings.NewReader(testDB))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		model, firmware, driveType string
+		id                         int
+		want                       Attribute
+	}{
+		{"Unknown", "", "", 1, Attribute{ID: 1, Format: "raw48", Name: "Raw_Read_Error_Rate"}},
+		{"Unknown", "", "HDD", 231, Attribute{ID: 231, Format: "raw48", Name: "Temperature_Celsius", DriveType: "HDD"}},
+		{"Unknown", "", "SSD", 231, Attribute{ID: 231, Format: "raw48", Name: "SSD_Life_Left", DriveType: "SSD"}},
+		{"Unknown", "", "", 231, Attribute{}},
+		// Presets for the drive type take precedence over presets for
+		// all drives.
+		{"Unknown", "", "SSD", 232, Attribute{ID: 232, Format: "raw48", Name: "Available_Reservd_Space", DriveType: "SSD"}},
+		{"Unknown", "", "HDD", 232, Attribute{ID: 232, Format: "raw48", Name: "Unknown_Attribute"}},
+		// Legacy presets are translated.
+		{"WDC WD20EFRX-68AX9N0", "80.00A80", "", 9, Attribute{ID: 9, Format: "min2hour", Name: "Power_On_Minutes"}},
+		// Presets without a name keep the default name.
+		{"WDC WD20EFRX-68AX9N0", "80.00A80", "", 194, Attribute{ID: 194, Format: "raw48", Name: "Temperature_Celsius"}},
+		{"WDC WD20EFRX-68AX9N0", "80.00A80", "HDD", 16, Attribute{}},
+		{"WDC WD20EFRX-68AX9N0", "80.00A80", "SSD", 16, Attribute{ID: 16, Format: "raw48", Name: `Total_LBAs_Read_"x"`, DriveType: "SSD"}},
+		{"ST1000DM003-9YN162", "CC46", "HDD", 240, Attribute{ID: 240, Format: "msec24hour32"}},
+	} {
+		if got := db.Attributes(tc.model, tc.firmware, tc.driveType)[tc.id]; got != tc.want {
+			t.Errorf("Attributes(%q, %q, %q)[%d] = %+v, want %+v", tc.model, tc.firmware, tc.driveType, tc.id, got, tc.want)
+		}
+	}
+}
+
+func TestEntryAttributes(t *testing.T) {
+	e := &Entry{Presets: "-v 9,raw24:543210,Power_On_Hours -F xerrorlba -v 0,raw48 -v 300,raw48 -v 194,10xCelsius -v"}
+	got := e.Attributes()
+	want := []Attribute{
+		{ID: 9, Format: "raw24", ByteOrder: "543210", Name: "Power_On_Hours"},
+		{ID: 194, Format: "temp10x", Name: "Temperature_Celsius_x10"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Attributes() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Attributes()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
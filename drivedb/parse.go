@@ -0,0 +1,152 @@
+package drivedb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// token is a C token relevant to drivedb.h: a string literal (adjacent
+// literals are concatenated), a punctuation character or any other word.
+type token struct {
+	punct byte
+	str   string
+	isStr bool
+}
+
+// tokenize splits C source into tokens. Comments and preprocessor lines are
+// skipped.
+func tokenize(r *bufio.Reader) ([]token, error) {
+	var toks []token
+	bol := true
+	for {
+		c, err := r.ReadByte()
+		if err == io.EOF {
+			return toks, nil
+		} else if err != nil {
+			return nil, err
+		}
+		switch {
+		case c == '\n':
+			bol = true
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			continue
+		case c == '#' && bol:
+			if _, err := r.ReadString('\n'); err != nil && err != io.EOF {
+				return nil, err
+			}
+			continue
+		}
+		bol = false
+		switch {
+		case c == '/':
+			n, _ := r.Peek(1)
+			switch {
+			case len(n) == 1 && n[0] == '/':
+				if _, err := r.ReadString('\n'); err != nil && err != io.EOF {
+					return nil, err
+				}
+				bol = true
+			case len(n) == 1 && n[0] == '*':
+				r.ReadByte()
+				if err := skipBlockComment(r); err != nil {
+					return nil, err
+				}
+			default:
+				toks = append(toks, token{punct: c})
+			}
+		case c == '"':
+			s, err := readString(r)
+			if err != nil {
+				return nil, err
+			}
+			if l := len(toks) - 1; l >= 0 && toks[l].isStr {
+				toks[l].str += s
+			} else {
+				toks = append(toks, token{str: s, isStr: true})
+			}
+		case strings.IndexByte("{},;=[]()", c) >= 0:
+			toks = append(toks, token{punct: c})
+		default:
+			// Identifiers and other words are not interesting, only
+			// their presence matters.
+			toks = append(toks, token{punct: '_'})
+			for {
+				n, err := r.Peek(1)
+				if err != nil || strings.IndexByte(" \t\r\n{},;=[]()\"/#", n[0]) >= 0 {
+					break
+				}
+				r.ReadByte()
+			}
+		}
+	}
+}
+
+func skipBlockComment(r *bufio.Reader) error {
+	for prev := byte(0); ; {
+		c, err := r.ReadByte()
+		if err == io.EOF {
+			return fmt.Errorf("drivedb: unterminated comment")
+		} else if err != nil {
+			return err
+		}
+		if prev == '*' && c == '/' {
+			return nil
+		}
+		prev = c
+	}
+}
+
+// readString reads a C string literal after the opening quote.
+func readString(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		c, err := r.ReadByte()
+		if err == io.EOF || c == '\n' {
+			return "", fmt.Errorf("drivedb: unterminated string %q", b.String())
+		} else if err != nil {
+			return "", err
+		}
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			if c, err = r.ReadByte(); err != nil {
+				return "", fmt.Errorf("drivedb: unterminated string %q", b.String())
+			}
+			switch c {
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			}
+		}
+		b.WriteByte(c)
+	}
+}
+
+// entryFields returns fields of a drive database entry if toks start with one:
+// { "family", "model", "firmware", "warning", "presets" }. The number of
+// tokens of the entry is returned as well.
+func entryFields(toks []token) ([]string, int) {
+	if len(toks) < 11 || toks[0].punct != '{' {
+		return nil, 0
+	}
+	var fields []string
+	for i := 1; i < 11; i += 2 {
+		if !toks[i].isStr {
+			return nil, 0
+		}
+		fields = append(fields, toks[i].str)
+		sep := byte(',')
+		if len(fields) == 5 {
+			sep = '}'
+		}
+		if toks[i+1].punct != sep {
+			return nil, 0
+		}
+	}
+	return fields, 11
+}
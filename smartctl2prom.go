@@ -1,174 +1,248 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
 
-	"badrpc/smartctl2prom/smartctldata"
+	"github.com/badrpc/smartctl2prom/drivedb"
+	"github.com/badrpc/smartctl2prom/smartctldata"
+	"github.com/badrpc/smartctl2prom/smartctlprom"
+	"github.com/badrpc/smartctl2prom/smartctlrun"
 )
 
+const usage = `usage: smartctl2prom <command> [flags]
+
+Commands:
+  textfile  Write metrics to a file for node_exporter textfile collector.
+  serve     Serve metrics over HTTP at /metrics.
+  convert   Write metrics to standard output.
+
+SMART data are read from smartctl output in files given with -input (standard
+input by default) or by running smartctl directly with -run.
+
+Run "smartctl2prom <command> -h" for flags of a command.
+`
+
+type command struct {
+	run  func(fs *flag.FlagSet, args []string, cfg *collectorConfig)
+	args string
+}
+
+var commands = map[string]command{
+	"textfile": {textfile, "{-output filename | filename}"},
+	"serve":    {serve, ""},
+	"convert":  {convert, ""},
+}
+
 func main() {
-	if len(os.Args) != 1 {
-		log.Fatal("usage: smartctl2prom filename")
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	name, args := os.Args[1], os.Args[2:]
+	cmd, ok := commands[name]
+	if !ok {
+		if strings.HasPrefix(name, "-") {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		// "smartctl2prom filename" is how the tool was used before
+		// commands were introduced.
+		name, cmd, args = "textfile", commands["textfile"], os.Args[1:]
 	}
 
-	// Standard registry in prometheus module adds a number of internal
-	// process metrics which result in duplicate metrics if more than one
-	// text file exprter does this. An empty registry will not have those.
-	reg := prometheus.NewRegistry()
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: smartctl2prom %s [flags] %s\n", name, cmd.args)
+		fs.PrintDefaults()
+	}
+	cmd.run(fs, args, collectorFlags(fs))
+}
+
+// stringsFlag is a flag which may be repeated to collect a list of values.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// collectorConfig is configuration of Collector set by flags.
+type collectorConfig struct {
+	inputs  stringsFlag
+	format  string
+	run     bool
+	runner  smartctlrun.Runner
+	driveDB string
+}
+
+// collectorFlags defines flags selecting where SMART data are read from and
+// how they are exported.
+func collectorFlags(fs *flag.FlagSet) *collectorConfig {
+	cfg := &collectorConfig{}
+	fs.Var(&cfg.inputs, "input", "File with smartctl output, - for standard input. May be repeated. Files are re-read every time metrics are collected. (default -)")
+	fs.StringVar(&cfg.format, "format", "auto", "Format of -input files: json, flat (smartctl --json=g), text (smartctl output without --json) or auto.")
+	fs.BoolVar(&cfg.run, "run", false, "Run smartctl on all devices found by smartctl --scan-open instead of reading -input.")
+	fs.StringVar(&cfg.runner.SmartCtl, "smartctl", "smartctl", "Path to smartctl binary used with -run.")
+	fs.IntVar(&cfg.runner.Workers, "workers", 4, "Number of devices read concurrently with -run.")
+	fs.DurationVar(&cfg.runner.Timeout, "timeout", time.Minute, "Time allowed for reading a single device with -run. smartctl is killed when it expires.")
+	fs.StringVar(&cfg.runner.NoCheck, "nocheck", "", "Passed to smartctl as -n option with -run, e.g. standby to not spin up sleeping disks. Last known data are exported for skipped disks.")
+	fs.StringVar(&cfg.runner.CacheDir, "cache_dir", "", "Directory to keep last known SMART data of disks skipped because of -nocheck between runs. Required with -nocheck by textfile. Must not be shared: files of disks not found are removed.")
+	fs.StringVar(&cfg.driveDB, "drivedb", "", "drivedb.h of smartmontools used to resolve vendor specific attribute names and raw value formats, e.g. /usr/local/share/smartmontools/drivedb.h.")
+	return cfg
+}
 
-	readTime := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "smart_device_read_time",
-		Help: "Time when SMART data were read from device.",
-	}, []string{
-		"device_name",
-		"device_type",
-		"device_model_family",
-		"device_model_name",
-		"device_serial_number",
-		"smartctl_exit_status",
-	})
-	reg.MustRegister(readTime)
-
-	deviceIdLabels := []string{"device_name", "device_serial_number"}
-	capacityBlocks := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "smart_device_user_capacity_blocks",
-	}, deviceIdLabels)
-	reg.MustRegister(capacityBlocks)
-	capacityBytes := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "smart_device_user_capacity_bytes",
-	}, deviceIdLabels)
-	reg.MustRegister(capacityBytes)
-	logicalBlockSize := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "smart_device_logical_block_size_bytes",
-	}, deviceIdLabels)
-	reg.MustRegister(logicalBlockSize)
-	physicalBlockSize := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "smart_device_physical_block_size_bytes",
-	}, deviceIdLabels)
-	reg.MustRegister(physicalBlockSize)
-	interfaceSpeed := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "smart_device_interface_speed_bps",
-	}, deviceIdLabels)
-	reg.MustRegister(interfaceSpeed)
-	selfAssessmentPassed := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "smart_device_overall_health_self_assessment_passed",
-	}, deviceIdLabels)
-	reg.MustRegister(selfAssessmentPassed)
-	powerOnHours := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "smart_device_power_on_hours",
-	}, deviceIdLabels)
-	reg.MustRegister(powerOnHours)
-	powerCycles := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "smart_device_power_cycles_total",
-	}, deviceIdLabels)
-	reg.MustRegister(powerCycles)
-	temperature := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "smart_device_temperature_celsius",
-	}, deviceIdLabels)
-	reg.MustRegister(temperature)
-
-	attributeLabels := []string{"id", "name", "prefailure", "device_name", "device_serial_number"}
-	attributeValue := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "smart_device_ata_attribute_value",
-	}, attributeLabels)
-	reg.MustRegister(attributeValue)
-	attributeWorst := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "smart_device_ata_attribute_worst",
-	}, attributeLabels)
-	reg.MustRegister(attributeWorst)
-	attributeThresh := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "smart_device_ata_attribute_thresh",
-	}, attributeLabels)
-	reg.MustRegister(attributeThresh)
-	attributeRaw := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "smart_device_ata_attribute_raw_value",
-	}, attributeLabels)
-	reg.MustRegister(attributeRaw)
-	// TODO(badrpc): have not figured out how to create _min and _max only
-	// when they are provided in smartctl output.
-	//
-	// attributeRawMin := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-	// 	Name: "smart_device_ata_attribute_raw_value_min",
-	// }, attributeLabels)
-	// reg.MustRegister(attributeRawMin)
-	// attributeRawMax := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-	// 	Name: "smart_device_ata_attribute_raw_value_max",
-	// }, attributeLabels)
-	// reg.MustRegister(attributeRawMax)
-
-	for oe := range smartctldata.DecodeJSON(os.Stdin) {
-		if oe.Err != nil {
-			log.Print(oe.Err)
-			continue
+// readsStdin reports whether SMART data are read from standard input.
+func (cfg *collectorConfig) readsStdin() bool {
+	if cfg.run {
+		return false
+	}
+	for _, name := range cfg.inputs {
+		if name == "-" {
+			return true
 		}
-		o := oe.O
-
-		readTime.With(prometheus.Labels{
-			"device_name":          o.Device.Name,
-			"device_type":          o.Device.Type,
-			"device_model_family":  o.ModelFamily,
-			"device_model_name":    o.ModelName,
-			"device_serial_number": o.SerialNumber,
-			"smartctl_exit_status": strconv.Itoa(o.SmartCtl.ExitStatus),
-		}).Set(float64(o.LocalTime.TimeT))
-		capacityBlocks.WithLabelValues(o.Device.Name, o.SerialNumber).Set(float64(o.UserCapacity.Blocks))
-		capacityBytes.WithLabelValues(o.Device.Name, o.SerialNumber).Set(float64(o.UserCapacity.Bytes))
-		logicalBlockSize.WithLabelValues(o.Device.Name, o.SerialNumber).Set(float64(o.LogicalBlockSize))
-		physicalBlockSize.WithLabelValues(o.Device.Name, o.SerialNumber).Set(float64(o.PhysicalBlockSize))
-		interfaceSpeed.WithLabelValues(o.Device.Name, o.SerialNumber).Set(float64(o.InterfaceSpeed.Current.UnitsPerSecond * o.InterfaceSpeed.Current.BitsPerUnit))
-		var selfAssessmentPassedVal = 0.0
-		if o.SMARTStatus.Passed {
-			selfAssessmentPassedVal = 1.0
+	}
+	return len(cfg.inputs) == 0
+}
+
+// collector returns a Collector configured by flags. It must only be called
+// after flags are parsed.
+func (cfg *collectorConfig) collector() *smartctlprom.Collector {
+	var src smartctlprom.Source = &cfg.runner
+	if !cfg.run {
+		f, ok := formats[cfg.format]
+		if !ok {
+			log.Fatalf("unknown -format %q", cfg.format)
 		}
-		selfAssessmentPassed.WithLabelValues(o.Device.Name, o.SerialNumber).Set(selfAssessmentPassedVal)
-		powerOnHours.WithLabelValues(o.Device.Name, o.SerialNumber).Set(float64(o.PowerOnTime.Hours))
-		powerCycles.WithLabelValues(o.Device.Name, o.SerialNumber).Set(float64(o.PowerCycleCount))
-		temperature.WithLabelValues(o.Device.Name, o.SerialNumber).Set(float64(o.Temperature.Current))
-
-		for _, a := range o.ATASMARTAttributes.Table {
-			preFailure := "no"
-			if a.Flags.Prefailure {
-				preFailure = "yes"
+		inputs := cfg.inputs
+		if len(inputs) == 0 {
+			inputs = stringsFlag{"-"}
+		}
+		src = inputSource{inputs, f}
+	} else if len(cfg.inputs) != 0 {
+		log.Fatal("-input and -run are mutually exclusive")
+	}
+	c := smartctlprom.NewCollector(src)
+	if cfg.driveDB != "" {
+		db, err := drivedb.Load(cfg.driveDB)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.DriveDB = db
+	}
+	return c
+}
+
+var formats = map[string]smartctldata.Format{
+	"json": smartctldata.FormatJSON,
+	"text": smartctldata.FormatText,
+	"flat": smartctldata.FormatFlat,
+	"auto": smartctldata.FormatAuto,
+}
+
+// inputSource reads smartctl output from files.
+type inputSource struct {
+	files  []string
+	format smartctldata.Format
+}
+
+func (s inputSource) ReadAll(ctx context.Context) ([]smartctldata.OutputOrError, error) {
+	var res []smartctldata.OutputOrError
+	for _, name := range s.files {
+		var r io.Reader = os.Stdin
+		if name != "-" {
+			f, err := os.Open(name)
+			if err != nil {
+				return nil, err
 			}
-			attrLabels := prometheus.Labels{
-				"id":                   strconv.Itoa(int(a.ID)),
-				"name":                 strings.ToLower(a.Name),
-				"prefailure":           preFailure,
-				"device_name":          o.Device.Name,
-				"device_serial_number": o.SerialNumber,
+			defer f.Close()
+			r = f
+		}
+		err := smartctldata.NewDecoder(r, s.format).Walk(ctx, func(o *smartctldata.Output, err error) error {
+			if err != nil {
+				err = fmt.Errorf("%s: %v", name, err)
 			}
-			attributeValue.With(attrLabels).Set(float64(a.Value))
-			attributeWorst.With(attrLabels).Set(float64(a.Worst))
-			attributeThresh.With(attrLabels).Set(float64(a.Threshold))
-			readRawValue(a, attributeRaw.With(attrLabels) /*, attributeRawMin.With(attrLabels), attributeRawMax.With(attrLabels)*/)
+			res = append(res, smartctldata.OutputOrError{O: o, Err: err})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
 		}
 	}
+	return res, nil
+}
 
-	if err := prometheus.WriteToTextfile(os.Args[1], reg); err != nil {
+// newRegistry returns a registry with a single collector c.
+func newRegistry(c *smartctlprom.Collector) *prometheus.Registry {
+	// Standard registry in prometheus module adds a number of internal
+	// process metrics which result in duplicate metrics if more than one
+	// text file exprter does this. An empty registry will not have those.
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	return reg
+}
+
+func textfile(fs *flag.FlagSet, args []string, cfg *collectorConfig) {
+	output := fs.String("output", "", "Text file to write metrics to. The file is replaced atomically.")
+	fs.Parse(args)
+	if *output == "" && fs.NArg() == 1 {
+		*output = fs.Arg(0)
+	} else if *output == "" || fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if cfg.run && cfg.runner.NoCheck != "" && cfg.runner.CacheDir == "" {
+		// Nothing would be exported for skipped disks without a cache kept
+		// in between runs.
+		log.Fatal("-nocheck requires -cache_dir with textfile")
+	}
+	if err := prometheus.WriteToTextfile(*output, newRegistry(cfg.collector())); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func readRawValue(a *smartctldata.SMARTAttribute, raw /*, min, max */ prometheus.Gauge) {
-	rawValue := a.Raw.Value
-	switch a.ID {
-	case 194: // Temperature_Celsius
-		switch {
-		case rawValue <= 0x00ffff:
-			raw.Set(float64(rawValue))
-		case rawValue <= 0xffffffffffff && rawValue > 0x0000ffffffff:
-			raw.Set(float64(rawValue & 0x00000000ffff))
-			// min.Set(float64((rawValue & 0x0000ffff0000) >> 16))
-			// max.Set(float64((rawValue & 0xffff00000000) >> 32))
-		default:
-			raw.Set(float64(rawValue))
+func serve(fs *flag.FlagSet, args []string, cfg *collectorConfig) {
+	listen := fs.String("listen", ":9633", "Address to serve metrics on.")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if cfg.readsStdin() {
+		log.Fatal("serve cannot re-read standard input, use -input file or -run")
+	}
+	reg := newRegistry(cfg.collector())
+	http.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{ErrorLog: log.New(os.Stderr, "", log.LstdFlags)}))
+	log.Fatal(http.ListenAndServe(*listen, nil))
+}
+
+func convert(fs *flag.FlagSet, args []string, cfg *collectorConfig) {
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	mfs, err := newRegistry(cfg.collector()).Gather()
+	if err != nil {
+		log.Fatal(err)
+	}
+	enc := expfmt.NewEncoder(os.Stdout, expfmt.FmtText)
+	for _, mf := range mfs {
+		if err := enc.Encode(mf); err != nil {
+			log.Fatal(err)
 		}
-	default:
-		raw.Set(float64(rawValue))
 	}
 }
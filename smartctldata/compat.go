@@ -0,0 +1,101 @@
+package smartctldata
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// JSONFormatMajor is the major version of smartctl JSON format understood by
+// this package. Changes of the minor version are backwards compatible.
+const JSONFormatMajor = 1
+
+// UnmarshalOutput decodes JSON output of smartctl into o. Unlike
+// json.Unmarshal it refuses output of an incompatible JSON format version
+// rather than decoding whatever fields happen to match, fills fields left
+// empty by JSON from the text output embedded with smartctl --json=o and
+// evens out differences between smartctl versions (see applyQuirks).
+func UnmarshalOutput(data []byte, o *Output) error {
+	var v struct {
+		JsonFormatVersion []int `json:"json_format_version"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if len(v.JsonFormatVersion) == 0 {
+		return fmt.Errorf("UnmarshalOutput: json_format_version is missing, not smartctl output")
+	}
+	if v.JsonFormatVersion[0] != JSONFormatMajor {
+		return fmt.Errorf("UnmarshalOutput: unsupported JSON format version %v, major version %d expected", v.JsonFormatVersion, JSONFormatMajor)
+	}
+	if err := json.Unmarshal(data, o); err != nil {
+		return err
+	}
+	mergeTextOutput(o)
+	o.applyQuirks()
+	return nil
+}
+
+// applyQuirks fills fields which depending on smartctl version and device
+// type are either reported in a common place or only in device specific
+// data. smartctl before 7.0 (text output) only reports power-on time, power
+// cycles and temperature in ATA attributes and some versions of smartctl
+// only report them for NVMe devices in NVMe health information log. Fields
+// already set are left unchanged.
+//
+// Quirks are applied by looking at which fields are present rather than at
+// SmartCtl.Version: fields such as power_on_time.minutes and the temperature
+// limits are reported by smartctl 7.0, 7.1 and 7.2+ under the same names when
+// the device provides them, and are otherwise missing rather than renamed, so
+// there is no handling specific to a version of smartctl. Output of a JSON
+// format smartctl does not promise to be compatible with is refused by
+// UnmarshalOutput instead of being decoded to zeros.
+func (o *Output) applyQuirks() {
+	if l := o.NVMeSMARTHealthInformationLog; l != nil {
+		if o.PowerOnTime.Hours == 0 {
+			o.PowerOnTime.Hours = l.PowerOnHours
+		}
+		if o.PowerCycleCount == 0 {
+			o.PowerCycleCount = l.PowerCycles
+		}
+		if o.Temperature.Current == 0 {
+			o.Temperature.Current = l.Temperature
+		}
+	}
+	var temperature190 int64
+	for _, a := range o.ATASMARTAttributes.Table {
+		if a == nil {
+			continue
+		}
+		switch rawValue := a.Raw.Value; a.ID {
+		case 9:
+			// Raw value may be packed with minutes or milliseconds,
+			// smartctl prints it as 12345h+12m+03.456s then.
+			raw, _ := ParseRawString(a.Raw.Text)
+			if minutes, ok := raw.Field("minutes"); ok {
+				rawValue = int64(raw.Value)
+				if o.PowerOnTime.Minutes == nil {
+					m := int64(minutes)
+					o.PowerOnTime.Minutes = &m
+				}
+			}
+			if o.PowerOnTime.Hours == 0 {
+				o.PowerOnTime.Hours = rawValue
+			}
+		case 12:
+			if o.PowerCycleCount == 0 {
+				o.PowerCycleCount = rawValue
+			}
+		case 190:
+			// Airflow temperature is only used if there is no
+			// attribute 194.
+			temperature190 = rawValue & 0xffff
+		case 194:
+			if o.Temperature.Current == 0 {
+				o.Temperature.Current = rawValue & 0xffff
+			}
+		}
+	}
+	if o.Temperature.Current == 0 {
+		o.Temperature.Current = temperature190
+	}
+}
@@ -0,0 +1,105 @@
+package smartctldata
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestUnmarshalOutputVersions decodes outputs shaped like those of smartctl
+// 7.0, 7.1 and 7.2 for the same drive. smartctl 7.0 leaves minutes out of
+// power_on_time and only reports the current temperature, later versions
+// report minutes and temperature limits from SCT status. Fields reported by
+// all versions must decode the same.
+func TestUnmarshalOutputVersions(t *testing.T) {
+	const attributes = `"ata_smart_attributes":{"revision":16,"table":[
+		{"id":9,"name":"Power_On_Hours","value":86,"worst":86,"thresh":0,"raw":{"value":740712,"string":"12345h+12m"}},
+		{"id":12,"name":"Power_Cycle_Count","value":100,"worst":100,"thresh":0,"raw":{"value":100,"string":"100"}},
+		{"id":194,"name":"Temperature_Celsius","value":65,"worst":53,"thresh":0,"raw":{"value":201863462947,"string":"35 (Min/Max 18/47)"}}]}`
+	for _, tc := range []struct {
+		version string
+		json    string
+		limits  bool
+	}{
+		{
+			version: "7.0",
+			json: `{"json_format_version":[1,0],"smartctl":{"version":[7,0],"svn_revision":"4883"},` + attributes + `,
+				"power_on_time":{"hours":12345},"power_cycle_count":100,"temperature":{"current":35}}`,
+		},
+		{
+			version: "7.1",
+			json: `{"json_format_version":[1,0],"smartctl":{"version":[7,1],"svn_revision":"5022"},` + attributes + `,
+				"power_on_time":{"hours":12345,"minutes":12},"power_cycle_count":100,
+				"temperature":{"current":35,"power_cycle_min":18,"power_cycle_max":47,"lifetime_min":10,"lifetime_max":55,"op_limit_max":60,"limit_min":0,"limit_max":70}}`,
+			limits: true,
+		},
+		{
+			version: "7.2",
+			json: `{"json_format_version":[1,0],"smartctl":{"version":[7,2],"svn_revision":"5155","build_info":"(local build)"},` + attributes + `,
+				"power_on_time":{"hours":12345,"minutes":12},"power_cycle_count":100,
+				"temperature":{"current":35,"power_cycle_min":18,"power_cycle_max":47,"lifetime_min":10,"lifetime_max":55,"op_limit_max":60,"limit_min":0,"limit_max":70,"lifetime_over_limit_minutes":0}}`,
+			limits: true,
+		},
+	} {
+		var o Output
+		if err := UnmarshalOutput([]byte(tc.json), &o); err != nil {
+			t.Errorf("smartctl %s: %v", tc.version, err)
+			continue
+		}
+		if o.PowerOnTime.Hours != 12345 || o.PowerOnTime.Minutes == nil || *o.PowerOnTime.Minutes != 12 {
+			t.Errorf("smartctl %s: PowerOnTime = %d hours %v minutes, want 12345 hours 12 minutes", tc.version, o.PowerOnTime.Hours, ptrString(o.PowerOnTime.Minutes))
+		}
+		if o.PowerCycleCount != 100 {
+			t.Errorf("smartctl %s: PowerCycleCount = %d, want 100", tc.version, o.PowerCycleCount)
+		}
+		if o.Temperature.Current != 35 {
+			t.Errorf("smartctl %s: Temperature.Current = %d, want 35", tc.version, o.Temperature.Current)
+		}
+		if got := o.Temperature.LifetimeMax != nil; got != tc.limits {
+			t.Errorf("smartctl %s: temperature limits decoded: %v, want %v", tc.version, got, tc.limits)
+		}
+		if tc.limits && (*o.Temperature.LifetimeMax != 55 || o.Temperature.OpLimitMax == nil || *o.Temperature.OpLimitMax != 60) {
+			t.Errorf("smartctl %s: Temperature = %+v, want lifetime max 55 and op limit max 60", tc.version, o.Temperature)
+		}
+	}
+}
+
+// TestUnmarshalOutputAttributesOnly checks that power-on time, power cycles
+// and temperature are filled from ATA attributes when smartctl does not
+// report them at the top level.
+func TestUnmarshalOutputAttributesOnly(t *testing.T) {
+	const data = `{"json_format_version":[1,0],"smartctl":{"version":[7,0]},"ata_smart_attributes":{"table":[
+		null,
+		{"id":9,"raw":{"value":740712,"string":"12345h+12m"}},
+		{"id":12,"raw":{"value":100,"string":"100"}},
+		{"id":190,"raw":{"value":36,"string":"36"}},
+		{"id":194,"raw":{"value":201863462947,"string":"35 (Min/Max 18/47)"}}]}}`
+	var o Output
+	if err := UnmarshalOutput([]byte(data), &o); err != nil {
+		t.Fatal(err)
+	}
+	if o.PowerOnTime.Hours != 12345 || o.PowerOnTime.Minutes == nil || *o.PowerOnTime.Minutes != 12 {
+		t.Errorf("PowerOnTime = %d hours %v minutes, want 12345 hours 12 minutes", o.PowerOnTime.Hours, ptrString(o.PowerOnTime.Minutes))
+	}
+	if o.PowerCycleCount != 100 {
+		t.Errorf("PowerCycleCount = %d, want 100", o.PowerCycleCount)
+	}
+	if o.Temperature.Current != 35 {
+		t.Errorf("Temperature.Current = %d, want 35", o.Temperature.Current)
+	}
+}
+
+func TestUnmarshalOutputFormatVersion(t *testing.T) {
+	for _, data := range []string{`{}`, `{"json_format_version":[2,0]}`} {
+		var o Output
+		if err := UnmarshalOutput([]byte(data), &o); err == nil {
+			t.Errorf("UnmarshalOutput(%s) succeeded", data)
+		}
+	}
+}
+
+func ptrString(p *int64) string {
+	if p == nil {
+		return "nil"
+	}
+	return fmt.Sprint(*p)
+}
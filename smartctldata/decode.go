@@ -2,7 +2,8 @@ package smartctldata
 
 import (
 	"bufio"
-	"encoding/json"
+	"bytes"
+	"context"
 	"io"
 )
 
@@ -11,16 +12,20 @@ type OutputOrError struct {
 	Err error
 }
 
+// Decode calls decoder repeatedly to decode outputs from r until it returns
+// io.EOF. Decoding stops after the first error, as decoder is not expected to
+// be able to continue.
 func Decode(r io.Reader, decoder func(io.Reader, *Output) error) chan OutputOrError {
 	ch := make(chan OutputOrError)
 	go func() {
+		defer close(ch)
 		for {
 			var o Output
 			if err := decoder(r, &o); err == io.EOF {
-				close(ch)
 				return
 			} else if err != nil {
 				ch <- OutputOrError{nil, err}
+				return
 			}
 			ch <- OutputOrError{&o, nil}
 		}
@@ -28,14 +33,106 @@ func Decode(r io.Reader, decoder func(io.Reader, *Output) error) chan OutputOrEr
 	return ch
 }
 
+// DecodeJSON decodes a stream of smartctl --json outputs.
 func DecodeJSON(r io.Reader) chan OutputOrError {
-	return Decode(r, func(r io.Reader, o *Output) error {
-		return json.NewDecoder(r).Decode(&o)
-	})
+	return decodeStream(r, FormatJSON)
 }
 
+// DecodeText decodes a stream of smartctl outputs without --json. Outputs are
+// separated by the smartctl banner or by "=== END ===" lines.
 func DecodeText(r io.Reader) chan OutputOrError {
-	return Decode(r, func(r io.Reader, o *Output) error {
-		return parseSMARTCtl(bufio.NewReader(r), o)
-	})
+	return decodeStream(r, FormatText)
+}
+
+// DecodeAuto decodes a stream of smartctl outputs in which each document may
+// be either JSON (smartctl --json), flat JSON (smartctl --json=g) or text
+// (smartctl without --json). The
+// format is detected for every document, so outputs of different smartctl
+// versions may be concatenated into a single stream.
+func DecodeAuto(r io.Reader) chan OutputOrError {
+	return decodeStream(r, FormatAuto)
+}
+
+// decodeStream decodes documents of the given format from r in a goroutine.
+func decodeStream(r io.Reader, format Format) chan OutputOrError {
+	ch := make(chan OutputOrError)
+	go func() {
+		defer close(ch)
+		err := NewDecoder(r, format).Walk(context.Background(), func(o *Output, err error) error {
+			ch <- OutputOrError{o, err}
+			return nil
+		})
+		if err != nil {
+			ch <- OutputOrError{nil, err}
+		}
+	}()
+	return ch
+}
+
+// Decoder decodes a stream of smartctl outputs. A document which cannot be
+// decoded is reported as a *DecodeError and decoding continues with the next
+// document.
+type Decoder struct {
+	s *splitter
+}
+
+// NewDecoder returns a Decoder reading documents of the given format from r.
+func NewDecoder(r io.Reader, format Format) *Decoder {
+	return &Decoder{newSplitter(r, format)}
+}
+
+// Next decodes the next output on the stream. It returns io.EOF at the end of
+// the stream and a *DecodeError if the document could not be decoded, in
+// which case Next may be called again to decode the next document. ctx is
+// checked before every document, but does not interrupt a blocked read from
+// the underlying reader.
+func (d *Decoder) Next(ctx context.Context) (*Output, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	doc, err := d.s.next()
+	if err != nil {
+		return nil, err
+	}
+	var o Output
+	if doc.err == nil {
+		doc.err = decodeDocument(doc, &o)
+	}
+	if doc.err != nil {
+		return nil, &DecodeError{Offset: doc.offset, Index: doc.index, Err: doc.err}
+	}
+	return &o, nil
+}
+
+// Walk calls fn for every output on the stream until the end of the stream.
+// Documents which could not be decoded are passed to fn as a *DecodeError with
+// a nil output. Walk stops and returns the error if fn returns an error,
+// reading the stream fails or ctx is done.
+func (d *Decoder) Walk(ctx context.Context, fn func(*Output, error) error) error {
+	for {
+		o, err := d.Next(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if _, ok := err.(*DecodeError); err != nil && !ok {
+			return err
+		}
+		if err := fn(o, err); err != nil {
+			return err
+		}
+	}
+}
+
+func decodeDocument(d *document, o *Output) error {
+	switch d.format {
+	case FormatJSON:
+		return UnmarshalOutput(d.data, o)
+	case FormatFlat:
+		return parseFlat(bufio.NewReader(bytes.NewReader(d.data)), o)
+	}
+	if err := parseSMARTCtl(bufio.NewReader(bytes.NewReader(d.data)), o); err != nil {
+		return err
+	}
+	o.applyQuirks()
+	return nil
 }
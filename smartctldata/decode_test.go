@@ -0,0 +1,167 @@
+package smartctldata
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecoderNext(t *testing.T) {
+	const (
+		jsonA = `{"json_format_version":[1,0],"serial_number":"A"}` + "\n"
+		jsonB = `{"json_format_version":[1,0],"serial_number":"B"}` + "\n"
+		flatC = "json = {};\n" +
+			"json.json_format_version = [];\n" +
+			"json.json_format_version[0] = 1;\n" +
+			"json.json_format_version[1] = 0;\n" +
+			"json.serial_number = \"C\";\n"
+		textD = "smartctl 7.0 2018-12-30 r4883 [FreeBSD 12.0-RELEASE amd64] (local build)\n" +
+			"\n" +
+			"=== START OF INFORMATION SECTION ===\n" +
+			"Serial Number:    D\n" +
+			"\n"
+		// jsonE is formatted by hand with braces at the beginning of
+		// lines.
+		jsonE = "{\n" +
+			"\"json_format_version\": [1, 0],\n" +
+			"\"smartctl\":\n" +
+			"{\n" +
+			"\"version\": [7, 1]\n" +
+			"},\n" +
+			"\"serial_number\": \"E\"\n" +
+			"}\n"
+	)
+	// result is a serial number of a decoded output or the offset of a
+	// document which could not be decoded. attributes are IDs of ATA
+	// SMART attributes of the output, they are only checked if not nil.
+	type result struct {
+		serial     string
+		offset     int64
+		attributes []int32
+	}
+	for _, tc := range []struct {
+		name   string
+		format Format
+		in     string
+		want   []result
+	}{
+		{
+			name:   "json",
+			format: FormatJSON,
+			in:     jsonA + jsonB,
+			want:   []result{{serial: "A"}, {serial: "B"}},
+		},
+		{
+			name:   "unsupported json format version",
+			format: FormatJSON,
+			in:     jsonA + `{"json_format_version":[2,0]}` + "\n" + jsonB,
+			want:   []result{{serial: "A"}, {offset: int64(len(jsonA))}, {serial: "B"}},
+		},
+		{
+			name:   "garbage between documents",
+			format: FormatJSON,
+			in:     jsonA + "garbage\n" + jsonB,
+			want:   []result{{serial: "A"}, {offset: int64(len(jsonA))}, {serial: "B"}},
+		},
+		{
+			name:   "truncated json",
+			format: FormatJSON,
+			in:     jsonA + `{"json_format_version":[1,0],`,
+			want:   []result{{serial: "A"}, {offset: int64(len(jsonA))}},
+		},
+		{
+			name:   "formatted json",
+			format: FormatJSON,
+			in:     jsonE + jsonA,
+			want:   []result{{serial: "E"}, {serial: "A"}},
+		},
+		{
+			name:   "formatted json auto",
+			format: FormatAuto,
+			in:     jsonE + textD,
+			want:   []result{{serial: "E"}, {serial: "D"}},
+		},
+		{
+			name:   "json truncated by text",
+			format: FormatAuto,
+			in:     `{"json_format_version":[1,0],` + "\n" + textD,
+			want:   []result{{offset: 0}, {serial: "D"}},
+		},
+		{
+			name:   "flat",
+			format: FormatFlat,
+			in:     flatC + flatC,
+			want:   []result{{serial: "C"}, {serial: "C"}},
+		},
+		{
+			// Output of smartctl --json=g filtered by grep.
+			name:   "sparse flat",
+			format: FormatFlat,
+			in: flatC +
+				"json.ata_smart_attributes.table[3].id = 5;\n" +
+				"json.ata_smart_attributes.table[7].id = 9;\n",
+			want: []result{{serial: "C", attributes: []int32{5, 9}}},
+		},
+		{
+			name:   "huge flat index",
+			format: FormatFlat,
+			in:     flatC + "json.x[99999999999] = 1;\n" + flatC,
+			want:   []result{{offset: 0}, {serial: "C"}},
+		},
+		{
+			name:   "text",
+			format: FormatText,
+			in:     textD + textD,
+			want:   []result{{serial: "D"}, {serial: "D"}},
+		},
+		{
+			name:   "mixed",
+			format: FormatAuto,
+			in:     jsonA + flatC + textD + jsonB,
+			want:   []result{{serial: "A"}, {serial: "C"}, {serial: "D"}, {serial: "B"}},
+		},
+		{
+			name:   "bad text document",
+			format: FormatAuto,
+			in:     jsonA + "smartctl 7.0\nno sections\n" + jsonB,
+			want:   []result{{serial: "A"}, {offset: int64(len(jsonA))}, {serial: "B"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewDecoder(strings.NewReader(tc.in), tc.format)
+			for i, want := range tc.want {
+				o, err := d.Next(context.Background())
+				if want.serial != "" {
+					if err != nil {
+						t.Fatalf("Next() #%d: %v", i, err)
+					}
+					if o.SerialNumber != want.serial {
+						t.Errorf("Next() #%d: SerialNumber = %q, want %q", i, o.SerialNumber, want.serial)
+					}
+					if want.attributes != nil {
+						var ids []int32
+						for _, a := range o.ATASMARTAttributes.Table {
+							ids = append(ids, a.ID)
+						}
+						if fmt.Sprint(ids) != fmt.Sprint(want.attributes) {
+							t.Errorf("Next() #%d: attributes %v, want %v", i, ids, want.attributes)
+						}
+					}
+					continue
+				}
+				de, ok := err.(*DecodeError)
+				if !ok {
+					t.Fatalf("Next() #%d = %+v, %v, want *DecodeError", i, o, err)
+				}
+				if de.Index != i || de.Offset != want.offset {
+					t.Errorf("Next() #%d: error at document %d offset %d, want document %d offset %d", i, de.Index, de.Offset, i, want.offset)
+				}
+			}
+			if o, err := d.Next(context.Background()); err != io.EOF {
+				t.Errorf("Next() at the end = %+v, %v, want io.EOF", o, err)
+			}
+		})
+	}
+}
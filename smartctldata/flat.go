@@ -0,0 +1,128 @@
+package smartctldata
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DecodeFlat decodes a stream of smartctl --json=g outputs.
+func DecodeFlat(r io.Reader) chan OutputOrError {
+	return decodeStream(r, FormatFlat)
+}
+
+// Flat: json.ata_smart_attributes.table[3].raw.value = 42;
+var (
+	flatLineRE = regexp.MustCompile(`^json((?:\.[A-Za-z0-9_]+|\[[0-9]+\])*) = (.*);$`)
+	flatElemRE = regexp.MustCompile(`\.([A-Za-z0-9_]+)|\[([0-9]+)\]`)
+)
+
+// parseFlat rebuilds JSON document from smartctl --json=g output and decodes
+// it into o, so that flat output is decoded exactly as smartctl --json is.
+func parseFlat(r *bufio.Reader, o *Output) error {
+	var root interface{}
+	for n := 1; ; n++ {
+		l, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if l = strings.TrimSpace(l); l != "" {
+			m := flatLineRE.FindStringSubmatch(l)
+			if m == nil {
+				return fmt.Errorf("parseFlat: line %d: malformed line %q", n, l)
+			}
+			if root, err = flatSet(root, flatElemRE.FindAllStringSubmatch(m[1], -1), m[2]); err != nil {
+				return fmt.Errorf("parseFlat: line %d: %v", n, err)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	if root == nil {
+		return fmt.Errorf("parseFlat: no smartctl output found")
+	}
+	b, err := json.Marshal(flatCompact(root))
+	if err != nil {
+		return fmt.Errorf("parseFlat: %v", err)
+	}
+	return UnmarshalOutput(b, o)
+}
+
+// maxFlatIndex limits array indexes in flat output. smartctl arrays are much
+// shorter, a larger index is a sign of broken input.
+const maxFlatIndex = 1 << 16
+
+// flatSet sets an element of v at path to the JSON value and returns the
+// updated v. Objects and arrays on the path are created as needed. Each path
+// element is a submatch of flatElemRE.
+func flatSet(v interface{}, path [][]string, value string) (interface{}, error) {
+	if len(path) == 0 {
+		switch value {
+		case "{}":
+			if _, ok := v.(map[string]interface{}); ok {
+				return v, nil
+			}
+			return map[string]interface{}{}, nil
+		case "[]":
+			if _, ok := v.([]interface{}); ok {
+				return v, nil
+			}
+			return []interface{}{}, nil
+		}
+		if !json.Valid([]byte(value)) {
+			return nil, fmt.Errorf("invalid value %s", value)
+		}
+		return json.RawMessage(value), nil
+	}
+
+	if key := path[0][1]; key != "" {
+		m, ok := v.(map[string]interface{})
+		if v == nil {
+			m = map[string]interface{}{}
+		} else if !ok {
+			return nil, fmt.Errorf("%s is not an object", path[0][0])
+		}
+		var err error
+		m[key], err = flatSet(m[key], path[1:], value)
+		return m, err
+	}
+
+	i, err := strconv.Atoi(path[0][2])
+	if err != nil || i >= maxFlatIndex {
+		return nil, fmt.Errorf("%s: array index out of range", path[0][0])
+	}
+	a, ok := v.([]interface{})
+	if v != nil && !ok {
+		return nil, fmt.Errorf("%s is not an array", path[0][0])
+	}
+	for len(a) <= i {
+		a = append(a, nil)
+	}
+	a[i], err = flatSet(a[i], path[1:], value)
+	return a, err
+}
+
+// flatCompact removes missing elements from arrays in v. Arrays are sparse
+// if some lines of the output were left out, e.g. by filtering it with grep.
+func flatCompact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = flatCompact(e)
+		}
+	case []interface{}:
+		a := v[:0]
+		for _, e := range v {
+			if e != nil {
+				a = append(a, flatCompact(e))
+			}
+		}
+		return a
+	}
+	return v
+}
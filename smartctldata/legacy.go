@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -26,6 +27,7 @@ var topParsers = map[string]lineParser{
 
 func parseSMARTCtl(r *bufio.Reader, o *Output) error {
 	var parser lineParser
+	sections := false
 	for done, input := false, false; !done; {
 		l, err := r.ReadString('\n')
 		if err != nil {
@@ -36,20 +38,44 @@ func parseSMARTCtl(r *bufio.Reader, o *Output) error {
 		}
 		input = true
 		l = strings.TrimSpace(l)
-		if parser == nil {
-			var ok bool
-			parser, ok = topParsers[l]
-			if ok && parser == nil {
+		if next, ok := topParsers[l]; ok {
+			// Section headers always start a new section, even if the
+			// previous section was not terminated by an empty line.
+			parser = next
+			sections = true
+			if next == nil {
 				done = true
 			}
 			continue
-
+		}
+		if parser == nil {
+			switch {
+			case textBannerRE.MatchString(l):
+				m := textBannerRE.FindStringSubmatch(l)
+				o.SmartCtl.Version[0], _ = strconv.Atoi(m[1])
+				o.SmartCtl.Version[1], _ = strconv.Atoi(m[2])
+				o.SmartCtl.SvnRevision = m[3]
+				o.SmartCtl.PlatformInfo = m[4]
+			case powerModeRE.MatchString(l):
+				// Text: Device is in STANDBY mode, exit(2)
+				o.SmartCtl.Messages = append(o.SmartCtl.Messages, Message{Text: l, Severity: "information"})
+			case strings.HasPrefix(l, "Warning: "):
+				// Text: Warning: ATA error count 3 inconsistent with error log pointer 5
+				o.SmartCtl.Messages = append(o.SmartCtl.Messages, Message{Text: l, Severity: "warning"})
+			case strings.HasPrefix(l, "Smartctl open device: "):
+				// Text: Smartctl open device: /dev/ada9 failed: No such file or directory
+				o.SmartCtl.Messages = append(o.SmartCtl.Messages, Message{Text: l, Severity: "error"})
+			}
+			continue
 		}
 		parser, err = parser.Parse(o, l)
 		if err != nil {
 			return err
 		}
 	}
+	if !sections && len(o.SmartCtl.Messages) == 0 {
+		return fmt.Errorf("parseSMARTCtl: no smartctl output found")
+	}
 
 	// smart_device_interface_speed_bps{device_name="/dev/ada0",device_serial_number="WD-WMC300098101"} 6e+09
 	// o.InterfaceSpeed.Current.UnitsPerSecond * o.InterfaceSpeed.Current.BitsPerUnit
@@ -57,20 +83,12 @@ func parseSMARTCtl(r *bufio.Reader, o *Output) error {
 	// "local_time": { "time_t": 1561919685, "asctime": "Sun Jun 30 18:34:45 2019 UTC" },
 	// smart_device_read_time{device_model_family="Western Digital Red",device_model_name="WDC WD20EFRX-68AX9N0",device_name="/dev/ada0",device_serial_number="WD-WMC300098101",device_type="atacam",smartctl_exit_status="0"} 1.561919685e+09
 
-	for i := range o.ATASMARTAttributes.Table {
-		switch rawValue := o.ATASMARTAttributes.Table[i].Raw.Value; o.ATASMARTAttributes.Table[i].ID {
-		case 9:
-			o.PowerOnTime.Hours = rawValue
-		case 12:
-			o.PowerCycleCount = rawValue
-		case 194:
-			o.Temperature.Current = rawValue & 0x00000000ffff
-		}
-	}
-
 	return nil
 }
 
+// Text: smartctl 6.6 2017-11-05 r4594 [FreeBSD 11.2-RELEASE amd64] (local build)
+var textBannerRE = regexp.MustCompile(`^smartctl (\d+)\.(\d+) \S+ r(\d+) \[([^]]+)\]`)
+
 const smartCtlDate = "Mon Jan _2 15:04:05 2006 MST"
 
 func parseInfo(o *Output, l string) (lineParser, error) {
@@ -145,21 +163,46 @@ func parseInfo(o *Output, l string) (lineParser, error) {
 			if o.LogicalBlockSize != 0 {
 				o.UserCapacity.Blocks = o.UserCapacity.Bytes / o.LogicalBlockSize
 			}
+		case "rotation rate":
+			// JSON: "rotation_rate": 7200
+			// Text: Rotation Rate:    7200 rpm
+			// Text: Rotation Rate:    Solid State Device
+			// Unknown rotation rates are not reported in JSON.
+			if v == "Solid State Device" {
+				var n int64
+				o.RotationRate = &n
+			} else if n, err := strconv.ParseInt(strings.TrimSuffix(v, " rpm"), 10, 64); err == nil {
+				o.RotationRate = &n
+			}
 		case "device is":
 			// JSON: "in_smartctl_database": true
 			// Text: Device is        In smartctl database [for details use: -P show]
 			o.InSmartCtlDatabase = strings.HasPrefix(v, "In smartctl database")
-		case "ata Version is":
+		case "ata version is":
 			// JSON "ata_version": { "string": "ACS-2 (minor revision not indicated)", "major_value": 1022, "minor_value": 0 },
 			// ATA Version is   ACS-2 (minor revision not indicated)
+			o.ATAVersion.Text = v
+		case "sata version is":
 			// JSON "sata_version": { "string": "SATA 3.0", "value": 62 }, "interface_speed": {"max": {} "current": {}}
 			// SATA Version is  SATA 3.0, 6.0 Gb/s (current: 6.0 Gb/s)
+			// SATA Version is  SATA 3.0
+			// SATA Version is  SATA 3.3, >6.0 Gb/s (4) (current: 6.0 Gb/s)
+			// Speeds smartctl does not know are not exported.
+			m := sataVersionRE.FindStringSubmatch(v)
+			o.SATAVersion.Text = m[1]
+			if parseSATASpeed(m[2], &o.InterfaceSpeed.Max) {
+				// Maximum speed value in JSON is a bitmask of all
+				// supported speeds, bit N is set for speed N.
+				n := o.InterfaceSpeed.Max.SATAValue
+				o.InterfaceSpeed.Max.SATAValue = (1<<uint(n+1) - 1) &^ 1
+			}
+			parseSATASpeed(m[3], &o.InterfaceSpeed.Current)
 		case "local time is":
 			o.LocalTime.AscTime = v
 			// JSON "local_time": { "time_t": 1561919685, "asctime": "Sun Jun 30 18:34:45 2019 UTC" },
 			// Text Local Time is    Mon Jul  1 16:57:20 2019 UTC
 			if t, err := time.Parse(smartCtlDate, v); err != nil {
-				return nil, fmt.Errorf("parseInfo: %v", v, err)
+				return nil, fmt.Errorf("parseInfo: cannot parse %q as time: %v", v, err)
 			} else {
 				o.LocalTime.TimeT = t.Unix()
 			}
@@ -176,10 +219,80 @@ func parseInfo(o *Output, l string) (lineParser, error) {
 	return lineParserFunc(parseInfo), nil
 }
 
+// Text: SATA 3.0, 6.0 Gb/s (current: 6.0 Gb/s)
+var sataVersionRE = regexp.MustCompile(`^(.*?)(?:, (.*?)(?: \(current: (.*)\))?)?$`)
+
+// sataSpeedValues maps speed strings to "sata_value" of current interface
+// speed in smartctl JSON output.
+var sataSpeedValues = map[string]int64{
+	"1.5 Gb/s": 1,
+	"3.0 Gb/s": 2,
+	"6.0 Gb/s": 3,
+}
+
+// parseSATASpeed sets s from v if v is one of the speeds in sataSpeedValues
+// and reports whether it did.
+// JSON: "current": { "sata_value": 3, "string": "6.0 Gb/s", "units_per_second": 60, "bits_per_unit": 100000000 }
+// Text: 6.0 Gb/s
+func parseSATASpeed(v string, s *SpeedSpec) bool {
+	n, ok := sataSpeedValues[v]
+	if !ok {
+		return false
+	}
+	f, _ := strconv.ParseFloat(strings.TrimSuffix(v, " Gb/s"), 64)
+	*s = SpeedSpec{
+		SATAValue:      n,
+		Text:           v,
+		UnitsPerSecond: int64(f*10 + 0.5),
+		BitsPerUnit:    100000000,
+	}
+	return true
+}
+
 func parseSMARTData(o *Output, l string) (lineParser, error) {
 	if l == "Vendor Specific SMART Attributes with Thresholds:" {
 		return &parseSMARTAttrs{}, nil
 	}
+	// Text: SMART Error Log Version: 1
+	if v := strings.TrimPrefix(l, "SMART Error Log Version: "); v != l {
+		el := &ErrorLog{}
+		if n, err := strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("parseSMARTData: cannot parse error log version %q: %v", v, err)
+		} else {
+			el.Revision = n
+		}
+		o.ATASMARTErrorLog.Summary = el
+		return &parseErrorLog{log: el}, nil
+	}
+	// Text: SMART Extended Comprehensive Error Log Version: 1 (1 sectors)
+	if v := strings.TrimPrefix(l, "SMART Extended Comprehensive Error Log Version: "); v != l {
+		el := &ErrorLog{}
+		if _, err := fmt.Sscanf(v, "%d (%d sectors)", &el.Revision, &el.Sectors); err != nil {
+			return nil, fmt.Errorf("parseSMARTData: cannot parse extended error log version %q: %v", v, err)
+		}
+		o.ATASMARTErrorLog.Extended = el
+		return &parseErrorLog{log: el}, nil
+	}
+	// Text: SMART Self-test log structure revision number 1
+	if v := strings.TrimPrefix(l, "SMART Self-test log structure revision number "); v != l {
+		st := &SelfTestLog{}
+		if n, err := strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("parseSMARTData: cannot parse self-test log revision %q: %v", v, err)
+		} else {
+			st.Revision = n
+		}
+		o.ATASMARTSelfTestLog.Standard = st
+		return &parseSelfTestLog{log: st}, nil
+	}
+	// Text: SMART Extended Self-test Log Version: 1 (1 sectors)
+	if v := strings.TrimPrefix(l, "SMART Extended Self-test Log Version: "); v != l {
+		st := &SelfTestLog{}
+		if _, err := fmt.Sscanf(v, "%d (%d sectors)", &st.Revision, &st.Sectors); err != nil {
+			return nil, fmt.Errorf("parseSMARTData: cannot parse extended self-test log version %q: %v", v, err)
+		}
+		o.ATASMARTSelfTestLog.Extended = st
+		return &parseSelfTestLog{log: st}, nil
+	}
 	if f := strings.SplitN(l, ":", 2); len(f) == 2 && f[0] == "SMART overall-health self-assessment test result" {
 		// JSON:   "smart_status": { "passed": true },
 		o.SMARTStatus.Passed = strings.EqualFold(strings.TrimSpace(f[1]), "PASSED")
@@ -201,7 +314,7 @@ type parseSMARTAttrs struct {
 
 func (p *parseSMARTAttrs) Parse(o *Output, l string) (lineParser, error) {
 	if l == "" {
-		return nil, nil
+		return lineParserFunc(parseSMARTData), nil
 	}
 	fs := strings.Fields(l)
 	if fs[0] == "ID#" {
@@ -249,27 +362,32 @@ func (p *parseSMARTAttrs) Parse(o *Output, l string) (lineParser, error) {
 
 	a.Name = strings.ToLower(fs[p.idx.name])
 
-	if n, err = strconv.ParseInt(fs[p.idx.value], 0, 32); err != nil {
+	if n, err = strconv.ParseInt(fs[p.idx.value], 10, 32); err != nil {
 		return nil, fmt.Errorf("parseSMARTAttrs: cannot parse attribute current value %q: %v", fs[p.idx.value], err)
 	}
 	a.Value = int32(n)
 
-	if n, err = strconv.ParseInt(fs[p.idx.worst], 0, 32); err != nil {
+	if n, err = strconv.ParseInt(fs[p.idx.worst], 10, 32); err != nil {
 		return nil, fmt.Errorf("parseSMARTAttrs: cannot parse attribute worst value %q: %v", fs[p.idx.worst], err)
 	}
 	a.Worst = int32(n)
 
-	if n, err = strconv.ParseInt(fs[p.idx.threshold], 0, 32); err != nil {
+	if n, err = strconv.ParseInt(fs[p.idx.threshold], 10, 32); err != nil {
 		return nil, fmt.Errorf("parseSMARTAttrs: cannot parse attribute threshold %q: %v", fs[p.idx.threshold], err)
 	}
 	a.Threshold = int32(n)
 
-	rawFields := strings.SplitN(fs[p.idx.raw_value], " ", 2)
-	if n, err = strconv.ParseInt(rawFields[0], 10, 64); err != nil {
-		return nil, fmt.Errorf("parseSMARTAttrs: cannot parse attribute raw value %q: %v", rawFields[0], err)
+	// Raw value is the last column and may contain spaces, e.g.
+	// "35 (Min/Max 18/47)".
+	// Text output only has the raw value formatted by smartctl, the main
+	// value of it is the best approximation of the raw value available.
+	// Value is left 0 for formats ParseRawString does not know, Text is
+	// kept either way.
+	rawText := strings.Join(fs[p.idx.raw_value:], " ")
+	if raw, ok := ParseRawString(rawText); ok {
+		a.Raw.Value = int64(raw.Value)
 	}
-	a.Raw.Value = n
-	a.Raw.Text = fs[p.idx.raw_value]
+	a.Raw.Text = rawText
 
 	if n, err = strconv.ParseInt(fs[p.idx.flag], 0, 32); err != nil {
 		return nil, fmt.Errorf("parseSMARTAttrs: cannot parse attribute flags %q: %v", fs[p.idx.flag], err)
@@ -329,6 +447,158 @@ func (p *parseSMARTAttrs) Parse(o *Output, l string) (lineParser, error) {
 	return p, nil
 }
 
+// Text: Error 2 occurred at disk power-on lifetime: 31000 hours (1291 days + 16 hours)
+// Text: Error 3 [2] occurred at disk power-on lifetime: 31000 hours (1291 days + 16 hours)
+var errorLogEntryRE = regexp.MustCompile(`^Error (\d+)(?: \[\d+\])? occurred at disk power-on lifetime: (\d+) hours`)
+
+type parseErrorLog struct {
+	log *ErrorLog
+}
+
+// Text:
+// SMART Error Log Version: 1
+// ATA Error Count: 2
+// ...
+// Error 2 occurred at disk power-on lifetime: 31000 hours (1291 days + 16 hours)
+// ...
+// 40 51 00 ff ff ff 0f  Error: UNC at LBA = 0x0fffffff = 268435455
+//
+// JSON:
+// "ata_smart_error_log": { "summary": { "revision": 1, "count": 2, "logged_count": 2, "table": [ { "error_number": 2, "lifetime_hours": 31000, "error_description": "Error: UNC at LBA = 0x0fffffff = 268435455", ... }, ... ] } }
+func (p *parseErrorLog) Parse(o *Output, l string) (lineParser, error) {
+	// Error log entries are separated by empty lines, the log ends with
+	// the next SMART log.
+	if strings.HasPrefix(l, "SMART ") {
+		return parseSMARTData(o, l)
+	}
+	for _, prefix := range []string{"ATA Error Count: ", "Device Error Count: "} {
+		if v := strings.TrimPrefix(l, prefix); v != l {
+			// Text: ATA Error Count: 9 (device log contains only the most recent five errors)
+			v = strings.SplitN(v, " ", 2)[0]
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parseErrorLog: cannot parse error count %q: %v", v, err)
+			}
+			p.log.Count = n
+			return p, nil
+		}
+	}
+	if m := errorLogEntryRE.FindStringSubmatch(l); m != nil {
+		var e ErrorLogEntry
+		var err error
+		if e.ErrorNumber, err = strconv.ParseInt(m[1], 10, 64); err != nil {
+			return nil, fmt.Errorf("parseErrorLog: cannot parse error number %q: %v", m[1], err)
+		}
+		if e.LifetimeHours, err = strconv.ParseInt(m[2], 10, 64); err != nil {
+			return nil, fmt.Errorf("parseErrorLog: cannot parse lifetime hours %q: %v", m[2], err)
+		}
+		p.log.Table = append(p.log.Table, &e)
+		p.log.LoggedCount++
+		return p, nil
+	}
+	if i := strings.Index(l, "Error: "); i >= 0 && len(p.log.Table) > 0 {
+		if e := p.log.Table[len(p.log.Table)-1]; e.ErrorDescription == "" {
+			e.ErrorDescription = l[i:]
+		}
+	}
+	return p, nil
+}
+
+var selfTestTypes = map[string]int64{
+	"Offline":            0,
+	"Short offline":      1,
+	"Extended offline":   2,
+	"Conveyance offline": 3,
+	"Selective offline":  4,
+	"Abort offline test": 127,
+	"Short captive":      129,
+	"Extended captive":   130,
+	"Conveyance captive": 131,
+	"Selective captive":  132,
+}
+
+var selfTestStatuses = map[string]int64{
+	"Completed without error":       0,
+	"Aborted by host":               1,
+	"Interrupted (host reset)":      2,
+	"Fatal or unknown error":        3,
+	"Completed: unknown failure":    4,
+	"Completed: electrical failure": 5,
+	"Completed: servo/seek failure": 6,
+	"Completed: read failure":       7,
+	"Completed: handling damage??":  8,
+	"Self-test routine in progress": 15,
+}
+
+type parseSelfTestLog struct {
+	log *SelfTestLog
+}
+
+// Text:
+// Num  Test_Description    Status                  Remaining  LifeTime(hours)  LBA_of_first_error
+// # 1  Short offline       Completed without error       00%     31443         -
+// # 2  Extended offline    Completed: read failure       90%     31000         12345678
+//
+// JSON:
+// { "type": { "value": 2, "string": "Extended offline" }, "status": { "value": 121, "string": "Completed: read failure", "remaining_percent": 90, "passed": false }, "lifetime_hours": 31000, "lba": 12345678 }
+func (p *parseSelfTestLog) Parse(o *Output, l string) (lineParser, error) {
+	if l == "" {
+		return lineParserFunc(parseSMARTData), nil
+	}
+	if !strings.HasPrefix(l, "#") {
+		// Header or "No self-tests have been logged."
+		return p, nil
+	}
+
+	// Test description is printed as %-19s right after "#%2d  ", status
+	// follows and may contain spaces, the remaining fields do not.
+	if len(l) < 25 {
+		return nil, fmt.Errorf("parseSelfTestLog: line %q is too short", l)
+	}
+	var e SelfTestEntry
+	e.Type.Text = strings.TrimSpace(l[5:24])
+	if v, ok := selfTestTypes[e.Type.Text]; ok {
+		e.Type.Value = v
+	}
+
+	fs := strings.Fields(l[24:])
+	if len(fs) < 4 {
+		return nil, fmt.Errorf("parseSelfTestLog: cannot split %q into status, remaining, lifetime and LBA", l[24:])
+	}
+	e.Status.Text = strings.Join(fs[:len(fs)-3], " ")
+	remaining, lifetime, lba := fs[len(fs)-3], fs[len(fs)-2], fs[len(fs)-1]
+
+	if n, err := strconv.ParseInt(strings.TrimSuffix(remaining, "%"), 10, 64); err != nil {
+		return nil, fmt.Errorf("parseSelfTestLog: cannot parse remaining percent %q: %v", remaining, err)
+	} else {
+		e.Status.RemainingPercent = n
+	}
+	if v, ok := selfTestStatuses[e.Status.Text]; ok {
+		e.Status.Value = v<<4 | e.Status.RemainingPercent/10
+		e.Status.Passed = v == 0
+	}
+
+	if n, err := strconv.ParseInt(lifetime, 10, 64); err != nil {
+		return nil, fmt.Errorf("parseSelfTestLog: cannot parse lifetime hours %q: %v", lifetime, err)
+	} else {
+		e.LifetimeHours = n
+	}
+	if lba != "-" {
+		if n, err := strconv.ParseInt(lba, 0, 64); err != nil {
+			return nil, fmt.Errorf("parseSelfTestLog: cannot parse LBA of first error %q: %v", lba, err)
+		} else {
+			e.LBA = n
+		}
+	}
+
+	p.log.Table = append(p.log.Table, &e)
+	p.log.Count++
+	if e.Failed() {
+		p.log.ErrorCountTotal++
+	}
+	return p, nil
+}
+
 func parseSmartCtl2Prom(o *Output, l string) (lineParser, error) {
 	if l == "" {
 		return nil, nil
@@ -346,7 +616,8 @@ func parseSmartCtl2Prom(o *Output, l string) (lineParser, error) {
 		if n, err = strconv.ParseInt(v, 10, 8); err != nil {
 			return nil, fmt.Errorf("parseSmartCtl2Prom: cannot parse exit code %q as decimal integer: %v", v, err)
 		}
-		o.SmartCtl.ExitStatus = int(n)
+		status := int(n)
+		o.SmartCtl.ExitStatus = &status
 	case "timestamp":
 		var n int64
 		var err error
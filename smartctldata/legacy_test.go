@@ -0,0 +1,242 @@
+package smartctldata
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseSMARTAttrsUnknownRawValue(t *testing.T) {
+	const in = "smartctl 6.6 2017-11-05 r4594 [FreeBSD 11.2-RELEASE amd64] (local build)\n" +
+		"\n" +
+		"=== START OF READ SMART DATA SECTION ===\n" +
+		"Vendor Specific SMART Attributes with Thresholds:\n" +
+		"ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_FAILED RAW_VALUE\n" +
+		"  9 Power_On_Hours          0x0032   058   058   000    Old_age   Always       -       12h+34x\n" +
+		"194 Temperature_Celsius     0x0022   112   100   000    Old_age   Always       -       0x000000000023\n" +
+		"\n"
+	o, err := NewDecoder(strings.NewReader(in), FormatText).Next(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		text  string
+		value int64
+	}{
+		{"12h+34x", 0},
+		{"0x000000000023", 35},
+	}
+	if len(o.ATASMARTAttributes.Table) != len(want) {
+		t.Fatalf("decoded %d attributes, want %d", len(o.ATASMARTAttributes.Table), len(want))
+	}
+	for i, w := range want {
+		a := o.ATASMARTAttributes.Table[i]
+		if a.Raw.Text != w.text || a.Raw.Value != w.value {
+			t.Errorf("attribute %d raw = %q %d, want %q %d", a.ID, a.Raw.Text, a.Raw.Value, w.text, w.value)
+		}
+	}
+}
+
+func TestParseSATAVersion(t *testing.T) {
+	for _, tc := range []struct {
+		line         string
+		version      string
+		max, current SpeedSpec
+	}{
+		{
+			line:    "SATA 3.0",
+			version: "SATA 3.0",
+		},
+		{
+			line:    "SATA 3.0, 6.0 Gb/s (current: 6.0 Gb/s)",
+			version: "SATA 3.0",
+			max:     SpeedSpec{SATAValue: 14, Text: "6.0 Gb/s", UnitsPerSecond: 60, BitsPerUnit: 100000000},
+			current: SpeedSpec{SATAValue: 3, Text: "6.0 Gb/s", UnitsPerSecond: 60, BitsPerUnit: 100000000},
+		},
+		{
+			line:    "SATA 2.6, 3.0 Gb/s (current: 1.5 Gb/s)",
+			version: "SATA 2.6",
+			max:     SpeedSpec{SATAValue: 6, Text: "3.0 Gb/s", UnitsPerSecond: 30, BitsPerUnit: 100000000},
+			current: SpeedSpec{SATAValue: 1, Text: "1.5 Gb/s", UnitsPerSecond: 15, BitsPerUnit: 100000000},
+		},
+		{
+			// Speeds unknown to smartctl are not exported.
+			line:    "SATA 3.3, >6.0 Gb/s (4) (current: 6.0 Gb/s)",
+			version: "SATA 3.3",
+			current: SpeedSpec{SATAValue: 3, Text: "6.0 Gb/s", UnitsPerSecond: 60, BitsPerUnit: 100000000},
+		},
+	} {
+		in := "smartctl 7.0 2018-12-30 r4883 [FreeBSD 12.0-RELEASE amd64] (local build)\n" +
+			"\n" +
+			"=== START OF INFORMATION SECTION ===\n" +
+			"SATA Version is:  " + tc.line + "\n" +
+			"\n"
+		o, err := NewDecoder(strings.NewReader(in), FormatText).Next(context.Background())
+		if err != nil {
+			t.Errorf("%q: %v", tc.line, err)
+			continue
+		}
+		if o.SATAVersion.Text != tc.version {
+			t.Errorf("%q: SATAVersion.Text = %q, want %q", tc.line, o.SATAVersion.Text, tc.version)
+		}
+		if o.InterfaceSpeed.Max != tc.max {
+			t.Errorf("%q: InterfaceSpeed.Max = %+v, want %+v", tc.line, o.InterfaceSpeed.Max, tc.max)
+		}
+		if o.InterfaceSpeed.Current != tc.current {
+			t.Errorf("%q: InterfaceSpeed.Current = %+v, want %+v", tc.line, o.InterfaceSpeed.Current, tc.current)
+		}
+	}
+}
+
+func TestParseSelfTestLog(t *testing.T) {
+	const log = "Num  Test_Description    Status                  Remaining  LifeTime(hours)  LBA_of_first_error\n" +
+		"# 1  Short offline       Completed without error       00%     31443         -\n" +
+		"# 2  Extended offline    Completed: read failure       90%     31000         12345678\n" +
+		"# 3  Short offline       Aborted by host               20%     30990         -\n"
+	// Output of smartctl -x, smartctl -a only prints the standard log.
+	const in = "smartctl 7.0 2018-12-30 r4883 [FreeBSD 12.0-RELEASE amd64] (local build)\n" +
+		"\n" +
+		"=== START OF READ SMART DATA SECTION ===\n" +
+		"SMART Extended Self-test Log Version: 1 (1 sectors)\n" +
+		log +
+		"\n" +
+		"SMART Self-test log structure revision number 1\n" +
+		log +
+		"\n"
+	o, err := NewDecoder(strings.NewReader(in), FormatText).Next(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SelfTestEntry{
+		{
+			Type:          SelfTestType{Value: 1, Text: "Short offline"},
+			Status:        SelfTestStatus{Value: 0, Text: "Completed without error", RemainingPercent: 0, Passed: true},
+			LifetimeHours: 31443,
+		},
+		{
+			Type:          SelfTestType{Value: 2, Text: "Extended offline"},
+			Status:        SelfTestStatus{Value: 0x79, Text: "Completed: read failure", RemainingPercent: 90},
+			LifetimeHours: 31000,
+			LBA:           12345678,
+		},
+		{
+			Type:          SelfTestType{Value: 1, Text: "Short offline"},
+			Status:        SelfTestStatus{Value: 0x12, Text: "Aborted by host", RemainingPercent: 20},
+			LifetimeHours: 30990,
+		},
+	}
+	for _, tc := range []struct {
+		name string
+		log  *SelfTestLog
+	}{
+		{"standard", o.ATASMARTSelfTestLog.Standard},
+		{"extended", o.ATASMARTSelfTestLog.Extended},
+	} {
+		if tc.log == nil {
+			t.Errorf("%s self-test log not decoded", tc.name)
+			continue
+		}
+		if tc.log.Count != 3 || tc.log.ErrorCountTotal != 1 {
+			t.Errorf("%s self-test log: Count = %d, ErrorCountTotal = %d, want 3, 1", tc.name, tc.log.Count, tc.log.ErrorCountTotal)
+		}
+		if len(tc.log.Table) != len(want) {
+			t.Errorf("%s self-test log: decoded %d entries, want %d", tc.name, len(tc.log.Table), len(want))
+			continue
+		}
+		for i, w := range want {
+			if e := tc.log.Table[i]; *e != w {
+				t.Errorf("%s self-test log: entry %d = %+v, want %+v", tc.name, i+1, *e, w)
+			}
+		}
+	}
+}
+
+func TestParseErrorLog(t *testing.T) {
+	// Output of smartctl -x, smartctl -a only prints the summary log.
+	const in = "smartctl 7.0 2018-12-30 r4883 [FreeBSD 12.0-RELEASE amd64] (local build)\n" +
+		"\n" +
+		"=== START OF READ SMART DATA SECTION ===\n" +
+		"SMART Extended Comprehensive Error Log Version: 1 (1 sectors)\n" +
+		"Device Error Count: 9 (device log contains only the most recent 4 errors)\n" +
+		"\tCR     = Command Register\n" +
+		"\tER     = Error register\n" +
+		"Error 9 [0] occurred at disk power-on lifetime: 31000 hours (1291 days + 16 hours)\n" +
+		"  When the command that caused the error occurred, the device was active or idle.\n" +
+		"\n" +
+		"  After command completion occurred, registers were:\n" +
+		"  ER -- ST COUNT  LBA_48  LH LM LL DV DC\n" +
+		"  -- -- -- == -- == == == -- -- -- -- --\n" +
+		"  40 -- 51 00 08 00 00 0f ff ff ff 40 00  Error: UNC at LBA = 0x0fffffff = 268435455\n" +
+		"\n" +
+		"  Commands leading to the command that caused the error were:\n" +
+		"  CR FEATR COUNT  LBA_48  LH LM LL DV DC  Powered_Up_Time  Command/Feature_Name\n" +
+		"  -- == -- == -- == == == -- -- -- -- --  ---------------  --------------------\n" +
+		"  60 00 00 00 08 00 00 0f ff ff ff 40 00  5d+09:12:54.123  READ FPDMA QUEUED\n" +
+		"\n" +
+		"Error 8 [3] occurred at disk power-on lifetime: 30990 hours (1291 days + 6 hours)\n" +
+		"  When the command that caused the error occurred, the device was active or idle.\n" +
+		"\n" +
+		"  After command completion occurred, registers were:\n" +
+		"  40 -- 51 00 08 00 00 0f ff ff fe 40 00  Error: UNC at LBA = 0x0ffffffe = 268435454\n" +
+		"\n" +
+		"SMART Error Log Version: 1\n" +
+		"ATA Error Count: 9 (device log contains only the most recent five errors)\n" +
+		"\tCR = Command Register [HEX]\n" +
+		"Error 9 occurred at disk power-on lifetime: 31000 hours (1291 days + 16 hours)\n" +
+		"  When the command that caused the error occurred, the device was active or idle.\n" +
+		"\n" +
+		"  After command completion occurred, registers were:\n" +
+		"  ER ST SC SN CL CH DH\n" +
+		"  -- -- -- -- -- -- --\n" +
+		"  40 51 08 ff ff ff 0f  Error: UNC at LBA = 0x0fffffff = 268435455\n" +
+		"\n" +
+		"  Commands leading to the command that caused the error were:\n" +
+		"  CR FR SC SN CL CH DH DC   Powered_Up_Time  Command/Feature_Name\n" +
+		"  -- -- -- -- -- -- -- --  ----------------  --------------------\n" +
+		"  60 00 08 ff ff ff 4f 00   5d+09:12:54.123  READ FPDMA QUEUED\n" +
+		"\n" +
+		"Error 8 occurred at disk power-on lifetime: 30990 hours (1291 days + 6 hours)\n" +
+		"  When the command that caused the error occurred, the device was active or idle.\n" +
+		"\n" +
+		"  After command completion occurred, registers were:\n" +
+		"  40 51 08 fe ff ff 0f  Error: UNC at LBA = 0x0ffffffe = 268435454\n" +
+		"\n" +
+		"SMART Self-test log structure revision number 1\n" +
+		"No self-tests have been logged.  [To run self-tests, use: smartctl -t]\n" +
+		"\n"
+	o, err := NewDecoder(strings.NewReader(in), FormatText).Next(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ErrorLogEntry{
+		{ErrorNumber: 9, LifetimeHours: 31000, ErrorDescription: "Error: UNC at LBA = 0x0fffffff = 268435455"},
+		{ErrorNumber: 8, LifetimeHours: 30990, ErrorDescription: "Error: UNC at LBA = 0x0ffffffe = 268435454"},
+	}
+	for _, tc := range []struct {
+		name string
+		log  *ErrorLog
+	}{
+		{"summary", o.ATASMARTErrorLog.Summary},
+		{"extended", o.ATASMARTErrorLog.Extended},
+	} {
+		if tc.log == nil {
+			t.Errorf("%s error log not decoded", tc.name)
+			continue
+		}
+		if tc.log.Count != 9 || tc.log.LoggedCount != 2 {
+			t.Errorf("%s error log: Count = %d, LoggedCount = %d, want 9, 2", tc.name, tc.log.Count, tc.log.LoggedCount)
+		}
+		if len(tc.log.Table) != len(want) {
+			t.Errorf("%s error log: decoded %d entries, want %d", tc.name, len(tc.log.Table), len(want))
+			continue
+		}
+		for i, w := range want {
+			if e := tc.log.Table[i]; *e != w {
+				t.Errorf("%s error log: entry %d = %+v, want %+v", tc.name, i+1, *e, w)
+			}
+		}
+	}
+	if l := o.ATASMARTSelfTestLog.Standard; l == nil || l.Count != 0 {
+		t.Errorf("self-test log after error log = %+v, want empty log", l)
+	}
+}
@@ -0,0 +1,61 @@
+package smartctldata
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+)
+
+// mergeTextOutput fills fields of o left empty by JSON output with data parsed
+// from the text output embedded with smartctl --json=o. Older versions of
+// smartctl only report some sections in the text output. The embedded output
+// is best effort, o is left unchanged if it cannot be parsed.
+func mergeTextOutput(o *Output) {
+	if len(o.SmartCtl.Output) == 0 {
+		return
+	}
+	var t Output
+	r := bufio.NewReader(strings.NewReader(strings.Join(o.SmartCtl.Output, "\n")))
+	if err := parseSMARTCtl(r, &t); err != nil {
+		return
+	}
+	fillMissing(reflect.ValueOf(o).Elem(), reflect.ValueOf(&t).Elem())
+}
+
+// fillMissing fills parts of dst which JSON output left out with the
+// corresponding values in src: nil pointers, empty slices and maps and
+// structs with all fields set to zero values. Other fields are left
+// unchanged, as zero or [7, 0] in JSON is a value rather than a missing
+// field.
+func fillMissing(dst, src reflect.Value) {
+	switch dst.Kind() {
+	case reflect.Struct:
+		if isZero(dst) {
+			dst.Set(src)
+			return
+		}
+		for i := 0; i < dst.NumField(); i++ {
+			if dst.Type().Field(i).PkgPath == "" {
+				fillMissing(dst.Field(i), src.Field(i))
+			}
+		}
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(src)
+			return
+		}
+		fillMissing(dst.Elem(), src.Elem())
+	case reflect.Slice, reflect.Map:
+		if dst.Len() == 0 {
+			dst.Set(src)
+		}
+	}
+}
+
+// isZero reports whether v is the zero value of its type.
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
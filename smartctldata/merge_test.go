@@ -0,0 +1,60 @@
+package smartctldata
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestUnmarshalOutputMerge checks that values reported in JSON, including
+// zero values, are kept and only missing parts are filled from the embedded
+// text output.
+func TestUnmarshalOutputMerge(t *testing.T) {
+	lines, err := json.Marshal([]string{
+		"smartctl 7.1 2019-12-30 r5022 [x86_64-linux-5.4.0] (local build)",
+		"",
+		"=== START OF INFORMATION SECTION ===",
+		"Serial Number:    B",
+		"Rotation Rate:    7200 rpm",
+		"Device is:        In smartctl database [for details use: -P show]",
+		"",
+		"=== START OF READ SMART DATA SECTION ===",
+		"Vendor Specific SMART Attributes with Thresholds:",
+		"ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_FAILED RAW_VALUE",
+		"  5 Reallocated_Sector_Ct   0x0033   100   100   010    Pre-fail  Always       -       8",
+		"  9 Power_On_Hours          0x0032   058   058   000    Old_age   Always       -       12345",
+		"",
+		"SMART Self-test log structure revision number 1",
+		"Num  Test_Description    Status                  Remaining  LifeTime(hours)  LBA_of_first_error",
+		"# 1  Short offline       Completed without error       00%     12340         -",
+		"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := `{"json_format_version":[1,0],"smartctl":{"version":[7,0],"svn_revision":"4883","output":` + string(lines) + `},
+		"serial_number":"A","rotation_rate":0,"in_smartctl_database":false,"power_cycle_count":0,
+		"ata_smart_attributes":{"table":[{"id":5,"name":"Reallocated_Sector_Ct","raw":{"value":0,"string":"0"}}]}}`
+	var o Output
+	if err := UnmarshalOutput([]byte(data), &o); err != nil {
+		t.Fatal(err)
+	}
+	if o.SmartCtl.Version != [2]int{7, 0} || o.SmartCtl.SvnRevision != "4883" {
+		t.Errorf("smartctl version %v %s, want [7 0] 4883", o.SmartCtl.Version, o.SmartCtl.SvnRevision)
+	}
+	if o.SerialNumber != "A" {
+		t.Errorf("SerialNumber = %q, want %q", o.SerialNumber, "A")
+	}
+	if o.RotationRate == nil || *o.RotationRate != 0 {
+		t.Errorf("RotationRate = %s, want 0", ptrString(o.RotationRate))
+	}
+	if o.InSmartCtlDatabase {
+		t.Error("InSmartCtlDatabase = true, want false")
+	}
+	if tbl := o.ATASMARTAttributes.Table; len(tbl) != 1 || tbl[0].ID != 5 || tbl[0].Raw.Value != 0 {
+		t.Errorf("ATA SMART attributes not kept as reported in JSON")
+	}
+	// Missing in JSON.
+	if l := o.ATASMARTSelfTestLog.Standard; l == nil || l.Count != 1 || l.Table[0].LifetimeHours != 12340 {
+		t.Errorf("self-test log = %+v, want one entry at 12340 hours", l)
+	}
+}
@@ -0,0 +1,23 @@
+package smartctldata
+
+import (
+	"regexp"
+	"strings"
+)
+
+// smartctl -n standby prints "Device is in STANDBY mode, exit(2)" and exits
+// without reading the device if it is in a low power mode.
+var powerModeRE = regexp.MustCompile(`^Device is in ([A-Z_]+) mode`)
+
+// PowerMode returns the power mode reported by smartctl when it did not read
+// SMART data to avoid spinning up the device (see -n option of smartctl),
+// e.g. "standby", "sleep" or "idle". An empty string is returned if smartctl
+// did not skip the device.
+func (o *Output) PowerMode() string {
+	for _, m := range o.SmartCtl.Messages {
+		if sm := powerModeRE.FindStringSubmatch(m.Text); sm != nil {
+			return strings.ToLower(sm[1])
+		}
+	}
+	return ""
+}
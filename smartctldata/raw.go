@@ -0,0 +1,275 @@
+package smartctldata
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RawField is a component of a raw value of an ATA SMART attribute.
+type RawField struct {
+	Name  string
+	Value float64
+}
+
+// DecodedRawValue is a raw value of an ATA SMART attribute decoded according
+// to its format.
+type DecodedRawValue struct {
+	// Value is the main value, the one smartctl prints first, e.g. the
+	// current temperature or whole hours of power-on time.
+	Value float64
+	// Fields are other components of the raw value, if any, e.g. "min"
+	// and "max" temperatures or "minutes" of power-on time.
+	Fields []RawField
+}
+
+// Field returns value of the named field.
+func (d DecodedRawValue) Field(name string) (float64, bool) {
+	for _, f := range d.Fields {
+		if f.Name == name {
+			return f.Value, true
+		}
+	}
+	return 0, false
+}
+
+// DecodeRawValue decodes raw value of attribute a in a format used by
+// smartctl -v option and drive database of smartmontools, e.g. raw48,
+// raw24/raw32 or tempminmax. Only 48 bits of raw value are reported by
+// smartctl, so 56 and 64 bit formats are decoded as their 48 bit
+// counterparts. Byte order suffixes are not supported.
+func DecodeRawValue(a *SMARTAttribute, format string) (DecodedRawValue, error) {
+	raw := uint64(a.Raw.Value) & 0xffffffffffff
+	var b [6]uint64
+	var w [3]uint64
+	for i := range b {
+		b[i] = raw >> (8 * uint(i)) & 0xff
+	}
+	for i := range w {
+		w[i] = raw >> (16 * uint(i)) & 0xffff
+	}
+
+	var d DecodedRawValue
+	field := func(name string, v uint64) {
+		d.Fields = append(d.Fields, RawField{name, float64(v)})
+	}
+	switch format {
+	case "raw48", "hex48", "raw56", "hex56", "raw64", "hex64":
+		d.Value = float64(raw)
+	case "raw8":
+		d.Value = float64(raw)
+		for i := range b {
+			field(fmt.Sprintf("byte%d", i), b[i])
+		}
+	case "raw16":
+		d.Value = float64(raw)
+		for i := range w {
+			field(fmt.Sprintf("word%d", i), w[i])
+		}
+	case "raw16(raw16)":
+		// Text: 0 (2 0)
+		d.Value = float64(w[0])
+		field("word1", w[1])
+		field("word2", w[2])
+	case "raw16(avg16)":
+		// Text: 10 (Average 5)
+		d.Value = float64(w[0])
+		field("average", w[1])
+	case "raw24(raw8)":
+		// Text: 100 (0 3 0)
+		d.Value = float64(raw & 0xffffff)
+		field("byte3", b[3])
+		field("byte4", b[4])
+		field("byte5", b[5])
+	case "raw24/raw24":
+		// Text: 1/10
+		d.Value = float64(raw >> 24)
+		field("numerator", raw>>24)
+		field("denominator", raw&0xffffff)
+	case "raw24/raw32":
+		d.Value = float64(raw >> 32)
+		field("numerator", raw>>32)
+		field("denominator", raw&0xffffffff)
+	case "sec2hour":
+		// Text: 12345h+12m+03s
+		d.Value = float64(raw / 3600)
+		field("minutes", raw%3600/60)
+		field("seconds", raw%60)
+	case "min2hour":
+		// Text: 12345h+12m (1)
+		minutes := w[0] | w[1]<<16
+		d.Value = float64(minutes / 60)
+		field("minutes", minutes%60)
+		if w[2] != 0 {
+			field("word2", w[2])
+		}
+	case "halfmin2hour":
+		// Text: 12345h+12m
+		d.Value = float64(raw / 120)
+		field("minutes", raw%120/2)
+	case "msec24hour32":
+		// Text: 12345h+12m+03.456s
+		ms := raw >> 32
+		d.Value = float64(raw & 0xffffffff)
+		field("minutes", ms/60000)
+		field("seconds", ms%60000/1000)
+		field("milliseconds", ms%1000)
+	case "temp10x":
+		d.Value = float64(w[0]) / 10
+	case "tempminmax":
+		d = decodeTempMinMax(b, w)
+	default:
+		return d, fmt.Errorf("DecodeRawValue: unknown raw value format %q", format)
+	}
+	return d, nil
+}
+
+// decodeTempMinMax decodes temperature with optional minimum and maximum. As
+// smartctl does, it guesses one of the layouts used by vendors (from the most
+// significant byte, TT - current temperature, LL/HH - min/max, xx - 00 or ff,
+// CC - over temperature count):
+//
+//	xx HH xx LL xx TT
+//	00 00 HH LL xx TT
+//	00 00 00 HH LL TT
+//	CC CC HH LL xx TT
+//
+// Minimum and maximum are only reported if they are consistent with the
+// current temperature.
+func decodeTempMinMax(b [6]uint64, w [3]uint64) DecodedRawValue {
+	t := int64(int8(b[0]))
+	d := DecodedRawValue{Value: float64(t)}
+	if w[1] == 0 && w[2] == 0 {
+		return d
+	}
+	signExt := func(v uint64) bool { return v == 0 || v == 0xff }
+	var lo, hi int64
+	var count uint64
+	switch {
+	case signExt(b[1]) && signExt(b[3]) && signExt(b[5]):
+		lo, hi = int64(int8(b[2])), int64(int8(b[4]))
+	case signExt(b[1]) && w[2] == 0:
+		lo, hi = int64(int8(b[2])), int64(int8(b[3]))
+	case b[3] == 0 && w[2] == 0:
+		lo, hi = int64(int8(b[1])), int64(int8(b[2]))
+	case signExt(b[1]):
+		lo, hi, count = int64(int8(b[2])), int64(int8(b[3])), w[2]
+	default:
+		return d
+	}
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	if lo > t || t > hi {
+		return d
+	}
+	d.Fields = append(d.Fields, RawField{"min", float64(lo)}, RawField{"max", float64(hi)})
+	if count != 0 {
+		d.Fields = append(d.Fields, RawField{"over_limit_count", float64(count)})
+	}
+	return d
+}
+
+var (
+	// Raw: 35 (Min/Max 18/47)
+	// Raw: 35 (Min/Max 18/47 #12)
+	rawMinMaxRE = regexp.MustCompile(`^(-?\d+) \(Min/Max (-?\d+)/(-?\d+)(?: #(\d+))?\)$`)
+	// Raw: 12345h+12m+03.456s
+	// Raw: 12345h+12m
+	rawDurationRE = regexp.MustCompile(`^(\d+)h\+(\d+)m(?:\+(\d+)(?:\.(\d+))?s)?(?: \(\d+\))?$`)
+	// Raw: 0/14351
+	rawRatioRE = regexp.MustCompile(`^(\d+)/(\d+)$`)
+	// Raw: 10 (Average 5)
+	rawAverageRE = regexp.MustCompile(`^(\d+) \(Average (\d+)\)$`)
+	// Raw: 11 (0 3 0 0 0)
+	rawListRE = regexp.MustCompile(`^(\d+) \((\d+(?: \d+)*)\)$`)
+	// Raw: 42
+	// Raw: 35.0
+	rawNumberRE = regexp.MustCompile(`^-?\d+(?:\.\d+)?$`)
+	// Raw: 0x000012345678
+	rawHexRE = regexp.MustCompile(`^0x[0-9a-fA-F]{1,16}$`)
+)
+
+// ParseRawString parses raw value as printed by smartctl, which formats it
+// according to the raw value format of the attribute. Components are named
+// as in DecodeRawValue where the string has enough information to tell them:
+// "min", "max" and "over_limit_count" of temperature, "minutes", "seconds"
+// and "milliseconds" of time, "numerator" and "denominator" of a ratio and
+// "average". Numbers in parentheses after the main value are returned as
+// "part1", "part2" and so on otherwise. Plain numbers may be decimal
+// fractions (temp10x) or hexadecimal (hex48, hex56, hex64). false is returned
+// if s is not in any of the known forms.
+func ParseRawString(s string) (DecodedRawValue, bool) {
+	var d DecodedRawValue
+	num := func(s string) float64 {
+		v, _ := strconv.ParseFloat(s, 64)
+		return v
+	}
+	field := func(name, v string) {
+		d.Fields = append(d.Fields, RawField{name, num(v)})
+	}
+	s = strings.TrimSpace(s)
+	if m := rawMinMaxRE.FindStringSubmatch(s); m != nil {
+		d.Value = num(m[1])
+		field("min", m[2])
+		field("max", m[3])
+		if m[4] != "" {
+			field("over_limit_count", m[4])
+		}
+	} else if m := rawDurationRE.FindStringSubmatch(s); m != nil {
+		d.Value = num(m[1])
+		field("minutes", m[2])
+		if m[3] != "" {
+			field("seconds", m[3])
+		}
+		if m[4] != "" {
+			// Fraction of a second, e.g. 456 in 03.456s.
+			ms := (m[4] + "00")[:3]
+			field("milliseconds", ms)
+		}
+	} else if m := rawRatioRE.FindStringSubmatch(s); m != nil {
+		d.Value = num(m[1])
+		field("numerator", m[1])
+		field("denominator", m[2])
+	} else if m := rawAverageRE.FindStringSubmatch(s); m != nil {
+		d.Value = num(m[1])
+		field("average", m[2])
+	} else if m := rawListRE.FindStringSubmatch(s); m != nil {
+		d.Value = num(m[1])
+		for i, v := range strings.Fields(m[2]) {
+			field("part"+strconv.Itoa(i+1), v)
+		}
+	} else if rawNumberRE.MatchString(s) {
+		d.Value = num(s)
+	} else if rawHexRE.MatchString(s) {
+		v, _ := strconv.ParseUint(s[2:], 16, 64)
+		d.Value = float64(v)
+	} else {
+		return d, false
+	}
+	return d, true
+}
+
+// SeagateErrorRate splits raw value of error rate attributes of Seagate hard
+// drives (1 Raw_Read_Error_Rate, 7 Seek_Error_Rate and 195
+// Hardware_ECC_Recovered) into the number of errors in the upper 16 bits and
+// the number of operations in the lower 32 bits. If smartctl printed the raw
+// value as a ratio (raw24/raw32), the ratio is used instead, as text output
+// only has the numerator in Raw.Value. false is returned for other
+// attributes. It is up to the caller to check that the drive is made by
+// Seagate.
+func SeagateErrorRate(a *SMARTAttribute) (errors, operations int64, ok bool) {
+	switch a.ID {
+	case 1, 7, 195:
+		if d, ok := ParseRawString(a.Raw.Text); ok {
+			n, okn := d.Field("numerator")
+			m, okm := d.Field("denominator")
+			if okn && okm {
+				return int64(n), int64(m), true
+			}
+		}
+		return a.Raw.Value >> 32 & 0xffff, a.Raw.Value & 0xffffffff, true
+	}
+	return 0, 0, false
+}
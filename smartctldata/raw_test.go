@@ -0,0 +1,175 @@
+package smartctldata
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeRawValue(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		raw    int64
+		want   DecodedRawValue
+	}{
+		{"raw48", 0x12345678, DecodedRawValue{Value: 0x12345678}},
+		// Only 48 bits of raw value are reported by smartctl.
+		{"hex56", 0x7f0000000000001, DecodedRawValue{Value: 1}},
+		{"raw8", 0x050403020100, DecodedRawValue{Value: 0x050403020100, Fields: []RawField{
+			{"byte0", 0}, {"byte1", 1}, {"byte2", 2}, {"byte3", 3}, {"byte4", 4}, {"byte5", 5},
+		}}},
+		{"raw16", 0x000300020001, DecodedRawValue{Value: 0x000300020001, Fields: []RawField{
+			{"word0", 1}, {"word1", 2}, {"word2", 3},
+		}}},
+		{"raw16(raw16)", 0x000300020001, DecodedRawValue{Value: 1, Fields: []RawField{{"word1", 2}, {"word2", 3}}}},
+		{"raw16(avg16)", 0x0005000a, DecodedRawValue{Value: 10, Fields: []RawField{{"average", 5}}}},
+		{"raw24(raw8)", 0x000300000064, DecodedRawValue{Value: 100, Fields: []RawField{
+			{"byte3", 0}, {"byte4", 3}, {"byte5", 0},
+		}}},
+		{"raw24/raw24", 1<<24 | 10, DecodedRawValue{Value: 1, Fields: []RawField{{"numerator", 1}, {"denominator", 10}}}},
+		{"raw24/raw32", 3<<32 | 14351, DecodedRawValue{Value: 3, Fields: []RawField{{"numerator", 3}, {"denominator", 14351}}}},
+		{"sec2hour", 12345*3600 + 12*60 + 3, DecodedRawValue{Value: 12345, Fields: []RawField{{"minutes", 12}, {"seconds", 3}}}},
+		{"min2hour", 12345*60 + 12, DecodedRawValue{Value: 12345, Fields: []RawField{{"minutes", 12}}}},
+		{"min2hour", 1<<32 | 12345*60 + 12, DecodedRawValue{Value: 12345, Fields: []RawField{{"minutes", 12}, {"word2", 1}}}},
+		{"halfmin2hour", (12345*60 + 12) * 2, DecodedRawValue{Value: 12345, Fields: []RawField{{"minutes", 12}}}},
+		{"msec24hour32", 3456<<32 | 12345, DecodedRawValue{Value: 12345, Fields: []RawField{
+			{"minutes", 0}, {"seconds", 3}, {"milliseconds", 456},
+		}}},
+		{"temp10x", 355, DecodedRawValue{Value: 35.5}},
+		{"tempminmax", 35, DecodedRawValue{Value: 35}},
+		// xx HH xx LL xx TT
+		{"tempminmax", 0x002f00120023, DecodedRawValue{Value: 35, Fields: []RawField{{"min", 18}, {"max", 47}}}},
+		// 00 00 HH LL xx TT
+		{"tempminmax", 0x00002f120023, DecodedRawValue{Value: 35, Fields: []RawField{{"min", 18}, {"max", 47}}}},
+		// 00 00 00 HH LL TT
+		{"tempminmax", 0x0000002f1223, DecodedRawValue{Value: 35, Fields: []RawField{{"min", 18}, {"max", 47}}}},
+		// CC CC HH LL xx TT
+		{"tempminmax", 0x00052f120023, DecodedRawValue{Value: 35, Fields: []RawField{
+			{"min", 18}, {"max", 47}, {"over_limit_count", 5},
+		}}},
+		// Negative temperatures.
+		{"tempminmax", 0x0000000af6fe, DecodedRawValue{Value: -2, Fields: []RawField{{"min", -10}, {"max", 10}}}},
+		// Current temperature out of the range, min and max are not
+		// trusted.
+		{"tempminmax", 0x00002f120050, DecodedRawValue{Value: 80}},
+	} {
+		a := &SMARTAttribute{}
+		a.Raw.Value = tc.raw
+		got, err := DecodeRawValue(a, tc.format)
+		if err != nil {
+			t.Errorf("DecodeRawValue(%#x, %q): %v", tc.raw, tc.format, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("DecodeRawValue(%#x, %q) = %+v, want %+v", tc.raw, tc.format, got, tc.want)
+		}
+	}
+}
+
+func TestDecodeRawValueUnknownFormat(t *testing.T) {
+	if _, err := DecodeRawValue(&SMARTAttribute{}, "raw99"); err == nil {
+		t.Error("DecodeRawValue() of unknown format succeeded")
+	}
+}
+
+func TestParseRawString(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		want DecodedRawValue
+	}{
+		{"42", DecodedRawValue{Value: 42}},
+		{"-3", DecodedRawValue{Value: -3}},
+		{" 42 ", DecodedRawValue{Value: 42}},
+		// temp10x
+		{"35.5", DecodedRawValue{Value: 35.5}},
+		// hex48
+		{"0x000012345678", DecodedRawValue{Value: 0x12345678}},
+		// hex56
+		{"0x00000000abcdef", DecodedRawValue{Value: 0xabcdef}},
+		{"35 (Min/Max 18/47)", DecodedRawValue{Value: 35, Fields: []RawField{{"min", 18}, {"max", 47}}}},
+		{"35 (Min/Max 18/47 #12)", DecodedRawValue{Value: 35, Fields: []RawField{
+			{"min", 18}, {"max", 47}, {"over_limit_count", 12},
+		}}},
+		{"-2 (Min/Max -10/10)", DecodedRawValue{Value: -2, Fields: []RawField{{"min", -10}, {"max", 10}}}},
+		{"12345h+12m", DecodedRawValue{Value: 12345, Fields: []RawField{{"minutes", 12}}}},
+		{"12345h+12m (1)", DecodedRawValue{Value: 12345, Fields: []RawField{{"minutes", 12}}}},
+		{"12345h+12m+03s", DecodedRawValue{Value: 12345, Fields: []RawField{{"minutes", 12}, {"seconds", 3}}}},
+		{"12345h+12m+03.456s", DecodedRawValue{Value: 12345, Fields: []RawField{
+			{"minutes", 12}, {"seconds", 3}, {"milliseconds", 456},
+		}}},
+		{"12345h+12m+03.4s", DecodedRawValue{Value: 12345, Fields: []RawField{
+			{"minutes", 12}, {"seconds", 3}, {"milliseconds", 400},
+		}}},
+		{"0/14351", DecodedRawValue{Value: 0, Fields: []RawField{{"numerator", 0}, {"denominator", 14351}}}},
+		{"10 (Average 5)", DecodedRawValue{Value: 10, Fields: []RawField{{"average", 5}}}},
+		{"11 (0 3 0 0 0)", DecodedRawValue{Value: 11, Fields: []RawField{
+			{"part1", 0}, {"part2", 3}, {"part3", 0}, {"part4", 0}, {"part5", 0},
+		}}},
+	} {
+		got, ok := ParseRawString(tc.s)
+		if !ok {
+			t.Errorf("ParseRawString(%q) failed", tc.s)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParseRawString(%q) = %+v, want %+v", tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestParseRawStringUnknown(t *testing.T) {
+	for _, s := range []string{"", "0x", "0x00000000000000001", "35.", "12h", "1/2/3", "abc"} {
+		if got, ok := ParseRawString(s); ok {
+			t.Errorf("ParseRawString(%q) = %+v, want failure", s, got)
+		}
+	}
+}
+
+func TestSeagateErrorRate(t *testing.T) {
+	const (
+		raw48 = 37<<32 | 1096255824
+		json  = `{"json_format_version":[1,0],"ata_smart_attributes":{"table":[
+			{"id":1,"name":"Raw_Read_Error_Rate","raw":{"value":%d,"string":"%s"}},
+			{"id":7,"name":"Seek_Error_Rate","raw":{"value":%d,"string":"%s"}},
+			{"id":5,"name":"Reallocated_Sector_Ct","raw":{"value":0,"string":"0"}}]}}`
+		text = "smartctl 7.0 2018-12-30 r4883 [FreeBSD 12.0-RELEASE amd64] (local build)\n" +
+			"\n" +
+			"=== START OF READ SMART DATA SECTION ===\n" +
+			"Vendor Specific SMART Attributes with Thresholds:\n" +
+			"ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_FAILED RAW_VALUE\n" +
+			"  1 Raw_Read_Error_Rate     0x000f   117   099   006    Pre-fail  Always       -       %s\n" +
+			"  7 Seek_Error_Rate         0x000f   117   099   006    Pre-fail  Always       -       %s\n" +
+			"  5 Reallocated_Sector_Ct   0x0033   100   100   010    Pre-fail  Always       -       0\n" +
+			"\n"
+	)
+	for _, tc := range []struct {
+		name   string
+		format Format
+		in     string
+	}{
+		{"json raw48", FormatJSON, fmt.Sprintf(json, raw48, fmt.Sprint(raw48), raw48, fmt.Sprint(raw48))},
+		{"json raw24/raw32", FormatJSON, fmt.Sprintf(json, raw48, "37/1096255824", raw48, "37/1096255824")},
+		{"text raw48", FormatText, fmt.Sprintf(text, fmt.Sprint(raw48), fmt.Sprint(raw48))},
+		{"text raw24/raw32", FormatText, fmt.Sprintf(text, "37/1096255824", "37/1096255824")},
+	} {
+		o, err := NewDecoder(strings.NewReader(tc.in), tc.format).Next(context.Background())
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		for _, a := range o.ATASMARTAttributes.Table {
+			errors, operations, ok := SeagateErrorRate(a)
+			if a.ID == 5 {
+				if ok {
+					t.Errorf("%s: SeagateErrorRate(%d) succeeded", tc.name, a.ID)
+				}
+				continue
+			}
+			if !ok || errors != 37 || operations != 1096255824 {
+				t.Errorf("%s: SeagateErrorRate(%d) = %d, %d, %v, want 37, 1096255824, true", tc.name, a.ID, errors, operations, ok)
+			}
+		}
+	}
+}
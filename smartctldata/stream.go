@@ -0,0 +1,260 @@
+package smartctldata
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Format is a format of smartctl output.
+type Format int
+
+const (
+	// FormatAuto detects format of every document on a stream.
+	FormatAuto Format = iota
+	// FormatJSON is the output of smartctl --json.
+	FormatJSON
+	// FormatText is the traditional output of smartctl without --json.
+	FormatText
+	// FormatFlat is the output of smartctl --json=g.
+	FormatFlat
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatAuto:
+		return "auto"
+	case FormatJSON:
+		return "json"
+	case FormatText:
+		return "text"
+	case FormatFlat:
+		return "flat"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// DecodeError is an error decoding a single document on a stream of smartctl
+// outputs. Decoding resumes at the start of the next document.
+type DecodeError struct {
+	// Offset is the byte offset of the start of the document in the stream.
+	Offset int64
+	// Index is the index of the document in the stream, starting at 0.
+	Index int
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("smartctldata: document %d at offset %d: %v", e.Index, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// textBanner starts every text output of smartctl.
+// Text: smartctl 6.6 2017-11-05 r4594 [FreeBSD 11.2-RELEASE amd64] (local build)
+const textBanner = "smartctl "
+
+// flatStart starts every output of smartctl --json=g.
+// Flat: json = {};
+const flatStart = "json = "
+
+// splitter splits a stream into documents without decoding them, so that
+// a malformed document does not affect documents which follow it. Documents
+// are expected to start at the beginning of a line, which is where smartctl
+// starts its output.
+type splitter struct {
+	r      *bufio.Reader
+	format Format
+	offset int64
+	index  int
+	// bol is true if r is positioned at the beginning of a line.
+	bol bool
+	// buf is reused for every document.
+	buf []byte
+}
+
+func newSplitter(r io.Reader, format Format) *splitter {
+	return &splitter{r: bufio.NewReader(r), format: format, bol: true}
+}
+
+// document is a single smartctl output on a stream.
+type document struct {
+	// data is only valid until the next call to splitter.next.
+	data   []byte
+	format Format
+	offset int64
+	index  int
+	// err is set if the document is malformed in a way which is detectable
+	// without decoding it.
+	err error
+}
+
+// next returns the next document on the stream. io.EOF is returned at the
+// end of the stream.
+func (s *splitter) next() (*document, error) {
+	if err := s.skipSpace(); err != nil {
+		return nil, err
+	}
+	d := &document{format: s.format, offset: s.offset, index: s.index}
+	s.index++
+	if d.format == FormatAuto {
+		if d.format = s.formatAt(); d.format == FormatAuto {
+			d.format = FormatText
+		}
+	}
+	var err error
+	if d.format == FormatJSON {
+		d.data, d.err, err = s.readJSON()
+	} else {
+		d.data, err = s.readText()
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.buf = d.data
+	return d, nil
+}
+
+func (s *splitter) readByte() (byte, error) {
+	b, err := s.r.ReadByte()
+	if err == nil {
+		s.offset++
+		s.bol = b == '\n'
+	}
+	return b, err
+}
+
+func (s *splitter) skipSpace() error {
+	for {
+		b, err := s.r.Peek(1)
+		if err != nil {
+			return err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			s.readByte()
+		default:
+			return nil
+		}
+	}
+}
+
+// formatAt returns the format of a document starting at the current position
+// of the stream or FormatAuto if no document starts there.
+func (s *splitter) formatAt() Format {
+	b, _ := s.r.Peek(len(textBanner))
+	switch {
+	case len(b) > 0 && b[0] == '{':
+		return FormatJSON
+	case string(b) == textBanner:
+		return FormatText
+	case len(b) >= len(flatStart) && string(b[:len(flatStart)]) == flatStart:
+		return FormatFlat
+	}
+	return FormatAuto
+}
+
+// atDocumentStart reports whether the stream is positioned at the start of
+// a document of the splitter's format.
+func (s *splitter) atDocumentStart() bool {
+	if !s.bol {
+		return false
+	}
+	f := s.formatAt()
+	return f != FormatAuto && (s.format == FormatAuto || f == s.format)
+}
+
+// atTextStart reports whether the stream is positioned at the start of text
+// or flat output, which can not be a part of a JSON document.
+func (s *splitter) atTextStart() bool {
+	if !s.bol {
+		return false
+	}
+	f := s.formatAt()
+	return f == FormatText || f == FormatFlat
+}
+
+// readJSON reads a JSON object up to its closing brace. The first returned
+// error reports a malformed document: anything other than an object or an
+// object cut short by the start of text or flat output. The second one is an
+// error reading the stream. A brace at the beginning of a line inside an
+// object does not start a new document, so JSON may be formatted in any way.
+func (s *splitter) readJSON() ([]byte, error, error) {
+	doc := s.buf[:0]
+	depth := 0
+	inString, escape := false, false
+	for {
+		if len(doc) > 0 && s.atTextStart() {
+			return doc, fmt.Errorf("truncated JSON document"), nil
+		}
+		b, err := s.readByte()
+		if err == io.EOF {
+			return doc, io.ErrUnexpectedEOF, nil
+		} else if err != nil {
+			return nil, nil, err
+		}
+		doc = append(doc, b)
+		switch {
+		case escape:
+			escape = false
+		case inString:
+			switch b {
+			case '\\':
+				escape = true
+			case '"':
+				inString = false
+			}
+		case depth == 0 && b != '{':
+			// Skip garbage up to the next document.
+			for !s.atDocumentStart() {
+				b, err := s.readByte()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					return nil, nil, err
+				}
+				doc = append(doc, b)
+			}
+			return doc, fmt.Errorf("unexpected input, JSON object expected"), nil
+		case b == '"':
+			inString = true
+		case b == '{' || b == '[':
+			depth++
+		case b == '}' || b == ']':
+			depth--
+			if depth == 0 {
+				return doc, nil, nil
+			}
+		}
+	}
+}
+
+// readText reads lines up to and including "=== END ===", up to the start of
+// the next document or up to the end of the stream. It is used for both text
+// and flat formats.
+func (s *splitter) readText() ([]byte, error) {
+	doc := s.buf[:0]
+	for {
+		if len(doc) > 0 && s.atDocumentStart() {
+			return doc, nil
+		}
+		l, err := s.r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// Long lines are kept whole, but only a line which fits
+			// into the buffer may end the document.
+			err = nil
+		}
+		s.offset += int64(len(l))
+		s.bol = len(l) > 0 && l[len(l)-1] == '\n'
+		doc = append(doc, l...)
+		if err == io.EOF {
+			return doc, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if s.bol && string(bytes.TrimSpace(l)) == "=== END ===" {
+			return doc, nil
+		}
+	}
+}
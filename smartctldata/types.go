@@ -25,18 +25,49 @@ type Output struct {
 	ATASctCapabilities ATASctCapabilities `json:"ata_sct_capabilities"`
 	ATASMARTAttributes ATASMARTAttributes `json:"ata_smart_attributes"`
 
+	ATASMARTErrorLog    ATASMARTErrorLog    `json:"ata_smart_error_log"`
+	ATASMARTSelfTestLog ATASMARTSelfTestLog `json:"ata_smart_self_test_log"`
+
+	NVMeSMARTHealthInformationLog *NVMeSMARTHealthInformationLog `json:"nvme_smart_health_information_log"`
+
+	SCSIErrorCounterLog                  *SCSIErrorCounterLog       `json:"scsi_error_counter_log"`
+	SCSIGrownDefectList                  *int64                     `json:"scsi_grown_defect_list"`
+	SCSIStartStopCycleCounter            *SCSIStartStopCycleCounter `json:"scsi_start_stop_cycle_counter"`
+	SCSIPercentageUsedEnduranceIndicator *int64                     `json:"scsi_percentage_used_endurance_indicator"`
+
 	PowerOnTime     PowerOnTime `json:"power_on_time"`
 	PowerCycleCount int64       `json:"power_cycle_count"`
 	Temperature     Temperature `json:"temperature"`
+
+	// RotationRate is 0 for solid state devices and nil if the device
+	// does not report it.
+	RotationRate *int64 `json:"rotation_rate"`
 }
 
 type Invocation struct {
 	Version      [2]int
 	SvnRevision  string `json:"svn_revision"`
 	PlatformInfo string `json:"platform_info"`
-	BuildInfo    string
+	BuildInfo    string `json:"build_info"`
 	Argv         []string
-	ExitStatus   int
+	Messages     []Message `json:"messages"`
+	// ExitStatus is nil if the exit status of smartctl is not known, e.g.
+	// for text output read without the smartctl2prom trailer.
+	ExitStatus *int `json:"exit_status"`
+	// Output holds lines of the text output with smartctl --json=o.
+	Output []string `json:"output"`
+}
+
+type Message struct {
+	Text     string `json:"string"`
+	Severity string `json:"severity"`
+}
+
+// ScanOutput matches the output of smartctl --scan or --scan-open.
+type ScanOutput struct {
+	JsonFormatVersion [2]int     `json:"json_format_version"`
+	SmartCtl          Invocation `json:"smartctl"`
+	Devices           []Device   `json:"devices"`
 }
 
 type Device struct {
@@ -111,9 +142,10 @@ type SelfTestData struct {
 }
 
 type SelfTestStatus struct {
-	Value  int64  `json:"value"`
-	Text   string `json:"string"`
-	Passed bool   `json:"passed"`
+	Value            int64  `json:"value"`
+	Text             string `json:"string"`
+	RemainingPercent int64  `json:"remaining_percent"`
+	Passed           bool   `json:"passed"`
 }
 
 type SelfTestTime struct {
@@ -174,10 +206,130 @@ type SMARTAttributeRawValue struct {
 	Text  string `json:"string"`
 }
 
+type ATASMARTErrorLog struct {
+	Summary  *ErrorLog `json:"summary"`
+	Extended *ErrorLog `json:"extended"`
+}
+
+type ErrorLog struct {
+	Revision int64 `json:"revision"`
+	Sectors  int64 `json:"sectors"`
+	// Count is the total number of errors reported by device. Only the
+	// most recent of them are logged in Table.
+	Count       int64            `json:"count"`
+	LoggedCount int64            `json:"logged_count"`
+	Table       []*ErrorLogEntry `json:"table"`
+}
+
+type ErrorLogEntry struct {
+	ErrorNumber      int64  `json:"error_number"`
+	LifetimeHours    int64  `json:"lifetime_hours"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type ATASMARTSelfTestLog struct {
+	Standard *SelfTestLog `json:"standard"`
+	Extended *SelfTestLog `json:"extended"`
+}
+
+type SelfTestLog struct {
+	Revision           int64            `json:"revision"`
+	Sectors            int64            `json:"sectors"`
+	Table              []*SelfTestEntry `json:"table"`
+	Count              int64            `json:"count"`
+	ErrorCountTotal    int64            `json:"error_count_total"`
+	ErrorCountOutdated int64            `json:"error_count_outdated"`
+}
+
+// SelfTestEntry is a single self-test log entry. Entries are ordered from the
+// most recent to the oldest.
+type SelfTestEntry struct {
+	Type          SelfTestType   `json:"type"`
+	Status        SelfTestStatus `json:"status"`
+	LifetimeHours int64          `json:"lifetime_hours"`
+	// LBA of the first error. Only set for failed tests.
+	LBA int64 `json:"lba"`
+}
+
+type SelfTestType struct {
+	Value int64  `json:"value"`
+	Text  string `json:"string"`
+}
+
+// Failed reports whether self-test completed with an error. Upper 4 bits of
+// status value are the self-test execution status. Values from 3 to 8 mean
+// the test has failed.
+func (e *SelfTestEntry) Failed() bool {
+	s := e.Status.Value >> 4
+	return s >= 3 && s <= 8
+}
+
+// PowerOnTime is power-on time of device. Minutes are only reported by
+// smartctl for devices which count power-on time in units smaller than an
+// hour and are nil otherwise.
 type PowerOnTime struct {
-	Hours int64 `json:"hours"`
+	Hours   int64  `json:"hours"`
+	Minutes *int64 `json:"minutes"`
 }
 
+// Temperature holds temperatures in degrees Celsius. Fields other than
+// Current are only reported by newer versions of smartctl and only for
+// devices which record them.
 type Temperature struct {
-	Current int64 `json:"current"`
+	Current                  int64  `json:"current"`
+	PowerCycleMin            *int64 `json:"power_cycle_min"`
+	PowerCycleMax            *int64 `json:"power_cycle_max"`
+	LifetimeMin              *int64 `json:"lifetime_min"`
+	LifetimeMax              *int64 `json:"lifetime_max"`
+	OpLimitMax               *int64 `json:"op_limit_max"`
+	LimitMin                 *int64 `json:"limit_min"`
+	LimitMax                 *int64 `json:"limit_max"`
+	LifetimeOverLimitMinutes *int64 `json:"lifetime_over_limit_minutes"`
+}
+
+type NVMeSMARTHealthInformationLog struct {
+	CriticalWarning         int64   `json:"critical_warning"`
+	Temperature             int64   `json:"temperature"`
+	AvailableSpare          int64   `json:"available_spare"`
+	AvailableSpareThreshold int64   `json:"available_spare_threshold"`
+	PercentageUsed          int64   `json:"percentage_used"`
+	DataUnitsRead           int64   `json:"data_units_read"`
+	DataUnitsWritten        int64   `json:"data_units_written"`
+	HostReads               int64   `json:"host_reads"`
+	HostWrites              int64   `json:"host_writes"`
+	ControllerBusyTime      int64   `json:"controller_busy_time"`
+	PowerCycles             int64   `json:"power_cycles"`
+	PowerOnHours            int64   `json:"power_on_hours"`
+	UnsafeShutdowns         int64   `json:"unsafe_shutdowns"`
+	MediaErrors             int64   `json:"media_errors"`
+	NumErrLogEntries        int64   `json:"num_err_log_entries"`
+	WarningTempTime         int64   `json:"warning_temp_time"`
+	CriticalCompTime        int64   `json:"critical_comp_time"`
+	TemperatureSensors      []int64 `json:"temperature_sensors"`
+}
+
+type SCSIErrorCounterLog struct {
+	Read   *SCSIErrorCounter `json:"read"`
+	Write  *SCSIErrorCounter `json:"write"`
+	Verify *SCSIErrorCounter `json:"verify"`
+}
+
+type SCSIErrorCounter struct {
+	ErrorsCorrectedByECCFast         int64 `json:"errors_corrected_by_eccfast"`
+	ErrorsCorrectedByECCDelayed      int64 `json:"errors_corrected_by_eccdelayed"`
+	ErrorsCorrectedByRereadsRewrites int64 `json:"errors_corrected_by_rereads_rewrites"`
+	TotalErrorsCorrected             int64 `json:"total_errors_corrected"`
+	CorrectionAlgorithmInvocations   int64 `json:"correction_algorithm_invocations"`
+	// smartctl reports processed amount as a decimal string, e.g. "1234.567".
+	GigabytesProcessed     string `json:"gigabytes_processed"`
+	TotalUncorrectedErrors int64  `json:"total_uncorrected_errors"`
+}
+
+type SCSIStartStopCycleCounter struct {
+	YearOfManufacture                          string `json:"year_of_manufacture"`
+	WeekOfManufacture                          string `json:"week_of_manufacture"`
+	SpecifiedCycleCountOverDeviceLifetime      int64  `json:"specified_cycle_count_over_device_lifetime"`
+	AccumulatedStartStopCycles                 int64  `json:"accumulated_start_stop_cycles"`
+	SpecifiedLoadUnloadCountOverDeviceLifetime int64  `json:"specified_load_unload_count_over_device_lifetime"`
+	AccumulatedLoadUnloadCycles                int64  `json:"accumulated_load_unload_cycles"`
 }
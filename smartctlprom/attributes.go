@@ -0,0 +1,110 @@
+package smartctlprom
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/badrpc/smartctl2prom/drivedb"
+	"github.com/badrpc/smartctl2prom/smartctldata"
+)
+
+// attribute exports attribute a. Name and raw value format of the attribute
+// are taken from preset if it sets them. seagate enables decoding of error
+// rate attributes of Seagate hard drives.
+func (e emitter) attribute(o *smartctldata.Output, a *smartctldata.SMARTAttribute, preset drivedb.Attribute, seagate bool) {
+	preFailure := "no"
+	if a.Flags.Prefailure {
+		preFailure = "yes"
+	}
+	name := a.Name
+	if preset.Name != "" {
+		name = preset.Name
+	}
+	labels := []string{
+		strconv.Itoa(int(a.ID)),
+		strings.ToLower(name),
+		preFailure,
+		o.Device.Name,
+		o.SerialNumber,
+	}
+	e.gauge(attributeValueDesc, float64(a.Value), labels...)
+	e.gauge(attributeWorstDesc, float64(a.Worst), labels...)
+	e.gauge(attributeThreshDesc, float64(a.Threshold), labels...)
+	switch preset.Format {
+	case "", "raw48", "raw24/raw32":
+	default:
+		// Raw value is not packed as errors and operations.
+		seagate = false
+	}
+	if seagate {
+		if errors, operations, ok := smartctldata.SeagateErrorRate(a); ok {
+			e.gauge(attributeErrorsDesc, float64(errors), labels...)
+			e.gauge(attributeOperationsDesc, float64(operations), labels...)
+		}
+	}
+	raw := readRawValue(a, preset.Format)
+	e.gauge(attributeRawDesc, raw.Value, labels...)
+	for _, f := range raw.Fields {
+		switch f.Name {
+		case "min":
+			e.gauge(attributeRawMinDesc, f.Value, labels...)
+		case "max":
+			e.gauge(attributeRawMaxDesc, f.Value, labels...)
+		default:
+			e.gauge(attributeRawFieldDesc, f.Value, append([]string{f.Name}, labels...)...)
+		}
+	}
+}
+
+// readRawValue returns raw value of attribute a decoded according to format.
+// format is a raw value format from drive database, an empty format is
+// guessed from attribute ID. Raw value is exported as is if its format is
+// not known.
+func readRawValue(a *smartctldata.SMARTAttribute, format string) smartctldata.DecodedRawValue {
+	// smartctl resolves vendor specific formats of raw value and prints
+	// its components in the raw string if it knows them.
+	if d, ok := smartctldata.ParseRawString(a.Raw.Text); ok && len(d.Fields) > 0 {
+		return d
+	}
+
+	if format == "" && (a.ID == 190 || a.ID == 194) {
+		// Airflow_Temperature_Cel, Temperature_Celsius
+		format = "tempminmax"
+	}
+	if format != "" {
+		if d, err := smartctldata.DecodeRawValue(a, format); err == nil {
+			return d
+		}
+	}
+	return smartctldata.DecodedRawValue{Value: float64(a.Raw.Value)}
+}
+
+// driveType returns HDD or SSD as drive type of presets in drive database
+// according to rotation rate of the drive, or an empty string if the drive
+// does not report it. smartctl reports rotation rate 0 for solid state
+// devices.
+func driveType(o *smartctldata.Output) string {
+	switch {
+	case o.RotationRate == nil:
+		return ""
+	case *o.RotationRate <= 1:
+		return "SSD"
+	default:
+		return "HDD"
+	}
+}
+
+// isSeagateHDD reports whether o is a Seagate hard drive according to model
+// family reported by smartctl or found in drive database. Drives of unknown
+// family are recognised by Seagate model names starting with ST.
+func isSeagateHDD(o *smartctldata.Output, db *drivedb.DB) bool {
+	family := o.ModelFamily
+	if e := db.Lookup(o.ModelName, o.FirmwareVersion); e != nil {
+		family = e.ModelFamily
+	}
+	if family == "" {
+		return strings.HasPrefix(o.ModelName, "ST")
+	}
+	// Error rate attributes of Seagate SSDs are not packed.
+	return strings.HasPrefix(family, "Seagate") && !strings.Contains(family, "SSD")
+}
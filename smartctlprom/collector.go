@@ -0,0 +1,533 @@
+// Package smartctlprom exports SMART data read by smartctl utility as
+// Prometheus metrics.
+package smartctlprom
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/badrpc/smartctl2prom/drivedb"
+	"github.com/badrpc/smartctl2prom/smartctldata"
+)
+
+// Source provides SMART data to Collector. ReadAll is called on every
+// collection. Errors reading individual devices are reported in the
+// returned slice, an error returned by ReadAll fails the whole collection.
+//
+// If an error reported for a device has a Timeout() bool method returning
+// true, the device is exported as timed out. O must hold Device in this
+// case.
+type Source interface {
+	ReadAll(ctx context.Context) ([]smartctldata.OutputOrError, error)
+}
+
+// SourceFunc is an adapter to use ordinary functions as Source.
+type SourceFunc func(ctx context.Context) ([]smartctldata.OutputOrError, error)
+
+func (f SourceFunc) ReadAll(ctx context.Context) ([]smartctldata.OutputOrError, error) {
+	return f(ctx)
+}
+
+// Collector is a prometheus.Collector exporting SMART data. SMART data are
+// read from Source and a fresh set of metrics is built on every collection,
+// so devices which disappear from Source disappear from metrics as well.
+// Concurrent collections share a single read from Source, so that
+// overlapping scrapes do not run smartctl on every disk more than once.
+type Collector struct {
+	src Source
+
+	// DriveDB is used to resolve vendor specific names and raw value
+	// formats of ATA attributes if set. Otherwise names reported by
+	// smartctl are exported.
+	DriveDB *drivedb.DB
+
+	mu sync.Mutex
+	// read is the read from Source in progress or nil.
+	read *sourceRead
+}
+
+// sourceRead is a single call to Source.ReadAll shared by collections.
+type sourceRead struct {
+	done chan struct{}
+	res  []smartctldata.OutputOrError
+	err  error
+}
+
+// NewCollector returns a Collector reading SMART data from src.
+func NewCollector(src Source) *Collector {
+	return &Collector{src: src}
+}
+
+var (
+	descs []*prometheus.Desc
+
+	deviceIdLabels = []string{"device_name", "device_serial_number"}
+
+	sourceErrorDesc = prometheus.NewDesc(
+		"smart_source_error", "SMART data could not be read.", nil, nil)
+)
+
+var (
+	smartctlVersionDesc = newDesc("smartctl_version_info",
+		"Version of smartctl which produced SMART data, always 1.",
+		[]string{"version", "svn_revision", "platform_info"})
+	jsonFormatVersionDesc = newDesc("smart_json_format_version",
+		"Version of smartctl JSON format, always 1.", []string{"version"})
+)
+
+func newDesc(name, help string, labels []string) *prometheus.Desc {
+	d := prometheus.NewDesc(name, help, labels, nil)
+	descs = append(descs, d)
+	return d
+}
+
+var (
+	readTimeDesc = newDesc("smart_device_read_time", "Time when SMART data were read from device.", deviceIdLabels)
+	infoDesc     = newDesc("smart_device_info", "Device identity reported by smartctl, always 1.", []string{
+		"device_name",
+		"device_serial_number",
+		"device_type",
+		"device_protocol",
+		"device_model_family",
+		"device_model_name",
+		"firmware_version",
+		"wwn",
+		"ata_version",
+		"sata_version",
+		"in_smartctl_database",
+	})
+	exitBitDesc = newDesc("smart_device_smartctl_exit_bit",
+		"Bits of smartctl exit status, see EXIT STATUS in smartctl(8). Not exported if exit status is not known or device was skipped because of low power mode.",
+		[]string{"bit", "device_name", "device_serial_number"})
+	collectionTimeoutDesc = newDesc("smart_device_collection_timeout",
+		"1 if smartctl was killed because it did not read SMART data from device in time.",
+		[]string{"device_name", "device_type"})
+	messagesDesc = newDesc("smart_device_smartctl_messages",
+		"Number of messages printed by smartctl while reading device, by severity.",
+		[]string{"severity", "device_name", "device_serial_number"})
+	powerModeDesc = newDesc("smart_device_power_mode",
+		"Power mode of device. Last known SMART data are exported if device was not read because it was in a low power mode.",
+		[]string{"mode", "device_name", "device_serial_number"})
+
+	capacityBlocksDesc       = newDesc("smart_device_user_capacity_blocks", "", deviceIdLabels)
+	capacityBytesDesc        = newDesc("smart_device_user_capacity_bytes", "", deviceIdLabels)
+	logicalBlockSizeDesc     = newDesc("smart_device_logical_block_size_bytes", "", deviceIdLabels)
+	physicalBlockSizeDesc    = newDesc("smart_device_physical_block_size_bytes", "", deviceIdLabels)
+	interfaceSpeedDesc       = newDesc("smart_device_interface_speed_bps", "", deviceIdLabels)
+	selfAssessmentPassedDesc = newDesc("smart_device_overall_health_self_assessment_passed", "", deviceIdLabels)
+	powerOnHoursDesc         = newDesc("smart_device_power_on_hours", "", deviceIdLabels)
+	powerCyclesDesc          = newDesc("smart_device_power_cycles_total", "", deviceIdLabels)
+	temperatureDesc          = newDesc("smart_device_temperature_celsius", "", deviceIdLabels)
+	temperatureStatDesc      = newDesc("smart_device_temperature_stat_celsius",
+		"Temperatures recorded by device (power_cycle_min, power_cycle_max, lifetime_min, lifetime_max) and its temperature limits (op_limit_max, limit_min, limit_max).",
+		[]string{"stat", "device_name", "device_serial_number"})
+	temperatureOverLimitDesc = newDesc("smart_device_temperature_over_limit_minutes_total",
+		"Time device spent over its temperature limit.", deviceIdLabels)
+	powerOnMinutesDesc = newDesc("smart_device_power_on_minutes",
+		"Power-on time in minutes. Only exported for devices which count power-on time with at least minute precision.", deviceIdLabels)
+
+	nvmeCriticalWarningDesc         = newDesc("smart_device_nvme_critical_warning", "", deviceIdLabels)
+	nvmeAvailableSpareDesc          = newDesc("smart_device_nvme_available_spare_percent", "", deviceIdLabels)
+	nvmeAvailableSpareThresholdDesc = newDesc("smart_device_nvme_available_spare_threshold_percent", "", deviceIdLabels)
+	nvmePercentageUsedDesc          = newDesc("smart_device_nvme_percentage_used", "", deviceIdLabels)
+	nvmeDataUnitsReadDesc           = newDesc("smart_device_nvme_data_units_read_total", "Number of 512000 byte data units read by the host.", deviceIdLabels)
+	nvmeDataUnitsWrittenDesc        = newDesc("smart_device_nvme_data_units_written_total", "Number of 512000 byte data units written by the host.", deviceIdLabels)
+	nvmeHostReadsDesc               = newDesc("smart_device_nvme_host_read_commands_total", "", deviceIdLabels)
+	nvmeHostWritesDesc              = newDesc("smart_device_nvme_host_write_commands_total", "", deviceIdLabels)
+	nvmeControllerBusyTimeDesc      = newDesc("smart_device_nvme_controller_busy_time_minutes_total", "", deviceIdLabels)
+	nvmeUnsafeShutdownsDesc         = newDesc("smart_device_nvme_unsafe_shutdowns_total", "", deviceIdLabels)
+	nvmeMediaErrorsDesc             = newDesc("smart_device_nvme_media_errors_total", "", deviceIdLabels)
+	nvmeErrorLogEntriesDesc         = newDesc("smart_device_nvme_error_log_entries_total", "", deviceIdLabels)
+	nvmeWarningTempTimeDesc         = newDesc("smart_device_nvme_warning_temperature_time_minutes_total", "", deviceIdLabels)
+	nvmeCriticalTempTimeDesc        = newDesc("smart_device_nvme_critical_temperature_time_minutes_total", "", deviceIdLabels)
+	nvmeTemperatureSensorDesc       = newDesc("smart_device_nvme_temperature_sensor_celsius", "", []string{"sensor", "device_name", "device_serial_number"})
+
+	scsiLabels                        = []string{"operation", "device_name", "device_serial_number"}
+	scsiErrorsCorrectedDesc           = newDesc("smart_device_scsi_errors_corrected_total", "", scsiLabels)
+	scsiErrorsUncorrectedDesc         = newDesc("smart_device_scsi_errors_uncorrected_total", "", scsiLabels)
+	scsiGigabytesProcessedDesc        = newDesc("smart_device_scsi_processed_gigabytes_total", "", scsiLabels)
+	scsiGrownDefectsDesc              = newDesc("smart_device_scsi_grown_defects", "", deviceIdLabels)
+	scsiStartStopCyclesDesc           = newDesc("smart_device_scsi_start_stop_cycles_total", "", deviceIdLabels)
+	scsiStartStopCyclesSpecifiedDesc  = newDesc("smart_device_scsi_start_stop_cycles_specified", "Start-stop cycle count specified over device lifetime.", deviceIdLabels)
+	scsiLoadUnloadCyclesDesc          = newDesc("smart_device_scsi_load_unload_cycles_total", "", deviceIdLabels)
+	scsiLoadUnloadCyclesSpecifiedDesc = newDesc("smart_device_scsi_load_unload_cycles_specified", "Load-unload cycle count specified over device lifetime.", deviceIdLabels)
+	scsiPercentageUsedDesc            = newDesc("smart_device_scsi_percentage_used_endurance_indicator", "", deviceIdLabels)
+
+	errorLogCountDesc = newDesc("smart_device_ata_error_log_count",
+		"Number of errors reported by device in SMART error log.", deviceIdLabels)
+	errorLogLastLifetimeHoursDesc = newDesc("smart_device_ata_error_log_last_error_lifetime_hours",
+		"Power-on hours of device when the most recent error in SMART error log occurred.", deviceIdLabels)
+
+	selfTestLabels         = []string{"type", "device_name", "device_serial_number"}
+	selfTestLastStatusDesc = newDesc("smart_device_ata_self_test_last_status",
+		"Execution status of the most recent self-test of a type: 0 completed without error, 1 aborted by host, 2 interrupted, 3-8 failed, 15 in progress.",
+		selfTestLabels)
+	selfTestLastFailedDesc = newDesc("smart_device_ata_self_test_last_failed",
+		"1 if the most recent self-test of a type has failed.", selfTestLabels)
+	selfTestLastLifetimeHoursDesc = newDesc("smart_device_ata_self_test_last_lifetime_hours",
+		"Power-on hours of device when the most recent self-test of a type was run.", selfTestLabels)
+	selfTestFailedDesc = newDesc("smart_device_ata_self_test_failed_count",
+		"Number of failed self-tests in self-test log.", deviceIdLabels)
+	selfTestLastFailureLBADesc = newDesc("smart_device_ata_self_test_last_failure_lba",
+		"LBA of the first error of the most recent failed self-test.", deviceIdLabels)
+
+	attributeLabels     = []string{"id", "name", "prefailure", "device_name", "device_serial_number"}
+	attributeValueDesc  = newDesc("smart_device_ata_attribute_value", "", attributeLabels)
+	attributeWorstDesc  = newDesc("smart_device_ata_attribute_worst", "", attributeLabels)
+	attributeThreshDesc = newDesc("smart_device_ata_attribute_thresh", "", attributeLabels)
+	attributeRawDesc    = newDesc("smart_device_ata_attribute_raw_value", "", attributeLabels)
+	attributeRawMinDesc = newDesc("smart_device_ata_attribute_raw_value_min",
+		"Minimum of raw value for attributes which record it, e.g. temperature.", attributeLabels)
+	attributeRawMaxDesc = newDesc("smart_device_ata_attribute_raw_value_max",
+		"Maximum of raw value for attributes which record it, e.g. temperature.", attributeLabels)
+	attributeErrorsDesc = newDesc("smart_device_ata_attribute_errors",
+		"Number of errors packed in raw value of error rate attributes of Seagate hard drives.", attributeLabels)
+	attributeOperationsDesc = newDesc("smart_device_ata_attribute_operations",
+		"Number of operations packed in raw value of error rate attributes of Seagate hard drives.", attributeLabels)
+	attributeRawFieldDesc = newDesc("smart_device_ata_attribute_raw_field",
+		"Components of raw value other than the main value exported as smart_device_ata_attribute_raw_value, e.g. minutes of power-on time. Depend on raw value format of attribute.",
+		append([]string{"field"}, attributeLabels...))
+)
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range descs {
+		ch <- d
+	}
+	ch <- sourceErrorDesc
+}
+
+// Collect implements prometheus.Collector. It reads SMART data from Source
+// and exports them.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	res, err := c.readAll()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(sourceErrorDesc, err)
+		return
+	}
+
+	// The same device may be present more than once, e.g. if it was
+	// listed twice in input. The last output wins, otherwise duplicate
+	// metrics would fail the whole collection.
+	var outputs []*smartctldata.Output
+	seen := map[[2]string]int{}
+	timedOut := map[[2]string]bool{}
+	for _, oe := range res {
+		if oe.Err != nil {
+			log.Print(oe.Err)
+			if t, ok := oe.Err.(interface{ Timeout() bool }); ok && t.Timeout() && oe.O != nil {
+				timedOut[[2]string{oe.O.Device.Name, oe.O.Device.Type}] = true
+			}
+			continue
+		}
+		k := [2]string{oe.O.Device.Name, oe.O.SerialNumber}
+		if i, ok := seen[k]; ok {
+			outputs[i] = oe.O
+			continue
+		}
+		seen[k] = len(outputs)
+		outputs = append(outputs, oe.O)
+	}
+
+	e := emitter{ch, c.DriveDB}
+	e.versions(outputs)
+	for k := range timedOut {
+		e.gauge(collectionTimeoutDesc, 1, k[0], k[1])
+	}
+	for _, o := range outputs {
+		// Devices with different serial numbers may share a name in
+		// input collected from more than one host.
+		if k := [2]string{o.Device.Name, o.Device.Type}; !timedOut[k] {
+			timedOut[k] = true
+			e.gauge(collectionTimeoutDesc, 0, k[0], k[1])
+		}
+		for _, m := range o.SmartCtl.Messages {
+			// Information messages, e.g. about power mode, would
+			// be logged on every collection.
+			if m.Severity != "information" {
+				log.Printf("%s (%s): smartctl %s: %s", o.Device.Name, o.SerialNumber, m.Severity, m.Text)
+			}
+		}
+		e.output(o)
+	}
+}
+
+// readAll reads SMART data from Source or waits for a read started by
+// another collection and returns its result.
+func (c *Collector) readAll() ([]smartctldata.OutputOrError, error) {
+	c.mu.Lock()
+	if r := c.read; r != nil {
+		c.mu.Unlock()
+		<-r.done
+		return r.res, r.err
+	}
+	r := &sourceRead{done: make(chan struct{})}
+	c.read = r
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.read = nil
+		c.mu.Unlock()
+		close(r.done)
+	}()
+	r.res, r.err = c.src.ReadAll(context.Background())
+	return r.res, r.err
+}
+
+// messageSeverities are severities of smartctl messages which are always
+// exported, so that alerts do not depend on presence of a series.
+var messageSeverities = [...]string{"information", "warning", "error"}
+
+// exitStatusBits are names of smartctl exit status bits from the least
+// significant one, see EXIT STATUS in smartctl(8).
+var exitStatusBits = [...]string{
+	"command_line_error",
+	"device_open_failed",
+	"command_failed",
+	"disk_failing",
+	"prefail_below_threshold",
+	"below_threshold_in_past",
+	"error_log_has_errors",
+	"self_test_log_has_errors",
+}
+
+type emitter struct {
+	ch chan<- prometheus.Metric
+	db *drivedb.DB
+}
+
+func (e emitter) gauge(d *prometheus.Desc, v float64, labels ...string) {
+	e.ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v, labels...)
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// versions exports versions of smartctl and its JSON format. Outputs
+// usually come from the same smartctl, so each version is only exported
+// once.
+func (e emitter) versions(outputs []*smartctldata.Output) {
+	seen := map[[3]string]bool{}
+	seenFormats := map[[2]int]bool{}
+	for _, o := range outputs {
+		if v := o.SmartCtl.Version; v != [2]int{} {
+			k := [3]string{fmt.Sprintf("%d.%d", v[0], v[1]), o.SmartCtl.SvnRevision, o.SmartCtl.PlatformInfo}
+			if !seen[k] {
+				seen[k] = true
+				e.gauge(smartctlVersionDesc, 1, k[:]...)
+			}
+		}
+		if v := o.JsonFormatVersion; v != [2]int{} && !seenFormats[v] {
+			seenFormats[v] = true
+			e.gauge(jsonFormatVersionDesc, 1, fmt.Sprintf("%d.%d", v[0], v[1]))
+		}
+	}
+}
+
+func (e emitter) output(o *smartctldata.Output) {
+	dev, sn := o.Device.Name, o.SerialNumber
+
+	mode := o.PowerMode()
+	if mode == "" {
+		mode = "active_or_idle"
+	}
+	e.gauge(powerModeDesc, 1, mode, dev, sn)
+	messages := map[string]int{}
+	for _, s := range messageSeverities {
+		messages[s] = 0
+	}
+	for _, m := range o.SmartCtl.Messages {
+		messages[m.Severity]++
+	}
+	for s, n := range messages {
+		e.gauge(messagesDesc, float64(n), s, dev, sn)
+	}
+	if mode != "active_or_idle" && sn == "" {
+		// Device was not read and there are no earlier data to export.
+		return
+	}
+
+	if status := o.SmartCtl.ExitStatus; status != nil && mode == "active_or_idle" {
+		// Device open failed bit is always set if smartctl skipped the
+		// device because of its power mode.
+		for i, bit := range exitStatusBits {
+			e.gauge(exitBitDesc, float64(*status>>uint(i)&1), bit, dev, sn)
+		}
+	}
+
+	wwn := ""
+	if o.WWN != (smartctldata.WWN{}) {
+		// Same format as "LU WWN Device Id" in smartctl text output.
+		wwn = fmt.Sprintf("%x %06x %09x", o.WWN.NAA, o.WWN.OUI, o.WWN.ID)
+	}
+	inDatabase := "no"
+	if o.InSmartCtlDatabase {
+		inDatabase = "yes"
+	}
+	e.gauge(infoDesc, 1,
+		dev,
+		sn,
+		o.Device.Type,
+		o.Device.Protocol,
+		o.ModelFamily,
+		o.ModelName,
+		o.FirmwareVersion,
+		wwn,
+		o.ATAVersion.Text,
+		o.SATAVersion.Text,
+		inDatabase)
+	e.gauge(readTimeDesc, float64(o.LocalTime.TimeT), dev, sn)
+	e.gauge(capacityBlocksDesc, float64(o.UserCapacity.Blocks), dev, sn)
+	e.gauge(capacityBytesDesc, float64(o.UserCapacity.Bytes), dev, sn)
+	e.gauge(logicalBlockSizeDesc, float64(o.LogicalBlockSize), dev, sn)
+	e.gauge(physicalBlockSizeDesc, float64(o.PhysicalBlockSize), dev, sn)
+	e.gauge(interfaceSpeedDesc, float64(o.InterfaceSpeed.Current.UnitsPerSecond*o.InterfaceSpeed.Current.BitsPerUnit), dev, sn)
+	e.gauge(selfAssessmentPassedDesc, boolToFloat(o.SMARTStatus.Passed), dev, sn)
+	e.gauge(powerOnHoursDesc, float64(o.PowerOnTime.Hours), dev, sn)
+	if m := o.PowerOnTime.Minutes; m != nil {
+		e.gauge(powerOnMinutesDesc, float64(o.PowerOnTime.Hours*60+*m), dev, sn)
+	}
+	e.gauge(powerCyclesDesc, float64(o.PowerCycleCount), dev, sn)
+	e.gauge(temperatureDesc, float64(o.Temperature.Current), dev, sn)
+	for _, t := range []struct {
+		stat  string
+		value *int64
+	}{
+		{"power_cycle_min", o.Temperature.PowerCycleMin},
+		{"power_cycle_max", o.Temperature.PowerCycleMax},
+		{"lifetime_min", o.Temperature.LifetimeMin},
+		{"lifetime_max", o.Temperature.LifetimeMax},
+		{"op_limit_max", o.Temperature.OpLimitMax},
+		{"limit_min", o.Temperature.LimitMin},
+		{"limit_max", o.Temperature.LimitMax},
+	} {
+		if t.value != nil {
+			e.gauge(temperatureStatDesc, float64(*t.value), t.stat, dev, sn)
+		}
+	}
+	if m := o.Temperature.LifetimeOverLimitMinutes; m != nil {
+		e.gauge(temperatureOverLimitDesc, float64(*m), dev, sn)
+	}
+
+	if l := o.NVMeSMARTHealthInformationLog; l != nil {
+		e.gauge(nvmeCriticalWarningDesc, float64(l.CriticalWarning), dev, sn)
+		e.gauge(nvmeAvailableSpareDesc, float64(l.AvailableSpare), dev, sn)
+		e.gauge(nvmeAvailableSpareThresholdDesc, float64(l.AvailableSpareThreshold), dev, sn)
+		e.gauge(nvmePercentageUsedDesc, float64(l.PercentageUsed), dev, sn)
+		e.gauge(nvmeDataUnitsReadDesc, float64(l.DataUnitsRead), dev, sn)
+		e.gauge(nvmeDataUnitsWrittenDesc, float64(l.DataUnitsWritten), dev, sn)
+		e.gauge(nvmeHostReadsDesc, float64(l.HostReads), dev, sn)
+		e.gauge(nvmeHostWritesDesc, float64(l.HostWrites), dev, sn)
+		e.gauge(nvmeControllerBusyTimeDesc, float64(l.ControllerBusyTime), dev, sn)
+		e.gauge(nvmeUnsafeShutdownsDesc, float64(l.UnsafeShutdowns), dev, sn)
+		e.gauge(nvmeMediaErrorsDesc, float64(l.MediaErrors), dev, sn)
+		e.gauge(nvmeErrorLogEntriesDesc, float64(l.NumErrLogEntries), dev, sn)
+		e.gauge(nvmeWarningTempTimeDesc, float64(l.WarningTempTime), dev, sn)
+		e.gauge(nvmeCriticalTempTimeDesc, float64(l.CriticalCompTime), dev, sn)
+		for i, t := range l.TemperatureSensors {
+			e.gauge(nvmeTemperatureSensorDesc, float64(t), strconv.Itoa(i+1), dev, sn)
+		}
+	}
+
+	if l := o.SCSIErrorCounterLog; l != nil {
+		for _, c := range []struct {
+			operation string
+			counter   *smartctldata.SCSIErrorCounter
+		}{
+			{"read", l.Read},
+			{"write", l.Write},
+			{"verify", l.Verify},
+		} {
+			if c.counter == nil {
+				continue
+			}
+			e.gauge(scsiErrorsCorrectedDesc, float64(c.counter.TotalErrorsCorrected), c.operation, dev, sn)
+			e.gauge(scsiErrorsUncorrectedDesc, float64(c.counter.TotalUncorrectedErrors), c.operation, dev, sn)
+			if gb, err := strconv.ParseFloat(c.counter.GigabytesProcessed, 64); err == nil {
+				e.gauge(scsiGigabytesProcessedDesc, gb, c.operation, dev, sn)
+			}
+		}
+	}
+	if o.SCSIGrownDefectList != nil {
+		e.gauge(scsiGrownDefectsDesc, float64(*o.SCSIGrownDefectList), dev, sn)
+	}
+	if c := o.SCSIStartStopCycleCounter; c != nil {
+		e.gauge(scsiStartStopCyclesDesc, float64(c.AccumulatedStartStopCycles), dev, sn)
+		e.gauge(scsiStartStopCyclesSpecifiedDesc, float64(c.SpecifiedCycleCountOverDeviceLifetime), dev, sn)
+		e.gauge(scsiLoadUnloadCyclesDesc, float64(c.AccumulatedLoadUnloadCycles), dev, sn)
+		e.gauge(scsiLoadUnloadCyclesSpecifiedDesc, float64(c.SpecifiedLoadUnloadCountOverDeviceLifetime), dev, sn)
+	}
+	if o.SCSIPercentageUsedEnduranceIndicator != nil {
+		e.gauge(scsiPercentageUsedDesc, float64(*o.SCSIPercentageUsedEnduranceIndicator), dev, sn)
+	}
+
+	// Extended comprehensive error log counts errors which do not fit in the
+	// summary log.
+	errorLog := o.ATASMARTErrorLog.Extended
+	if errorLog == nil {
+		errorLog = o.ATASMARTErrorLog.Summary
+	}
+	if errorLog != nil {
+		e.gauge(errorLogCountDesc, float64(errorLog.Count), dev, sn)
+		var last *smartctldata.ErrorLogEntry
+		for _, l := range errorLog.Table {
+			if l == nil {
+				continue
+			}
+			if last == nil || l.ErrorNumber > last.ErrorNumber {
+				last = l
+			}
+		}
+		if last != nil {
+			e.gauge(errorLogLastLifetimeHoursDesc, float64(last.LifetimeHours), dev, sn)
+		}
+	}
+
+	// Extended self-test log holds more entries than the standard one if
+	// both are present.
+	selfTestLog := o.ATASMARTSelfTestLog.Extended
+	if selfTestLog == nil {
+		selfTestLog = o.ATASMARTSelfTestLog.Standard
+	}
+	if selfTestLog != nil {
+		e.gauge(selfTestFailedDesc, float64(selfTestLog.ErrorCountTotal), dev, sn)
+		seen := map[string]bool{}
+		failureSeen := false
+		// Entries are ordered from the most recent.
+		for _, t := range selfTestLog.Table {
+			if t == nil {
+				continue
+			}
+			if !seen[t.Type.Text] {
+				seen[t.Type.Text] = true
+				e.gauge(selfTestLastStatusDesc, float64(t.Status.Value>>4), t.Type.Text, dev, sn)
+				e.gauge(selfTestLastFailedDesc, boolToFloat(t.Failed()), t.Type.Text, dev, sn)
+				e.gauge(selfTestLastLifetimeHoursDesc, float64(t.LifetimeHours), t.Type.Text, dev, sn)
+			}
+			if !failureSeen && t.Failed() {
+				failureSeen = true
+				e.gauge(selfTestLastFailureLBADesc, float64(t.LBA), dev, sn)
+			}
+		}
+	}
+
+	presets := e.db.Attributes(o.ModelName, o.FirmwareVersion, driveType(o))
+	seagate := isSeagateHDD(o, e.db)
+	for _, a := range o.ATASMARTAttributes.Table {
+		// JSON null entries decode to nil.
+		if a == nil {
+			continue
+		}
+		e.attribute(o, a, presets[int(a.ID)], seagate)
+	}
+}
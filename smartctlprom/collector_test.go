@@ -0,0 +1,182 @@
+package smartctlprom
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/badrpc/smartctl2prom/smartctldata"
+)
+
+// collect returns metrics collected by c.
+func collect(c prometheus.Collector) []prometheus.Metric {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	var metrics []prometheus.Metric
+	for m := range ch {
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+func TestCollectSharesRead(t *testing.T) {
+	var calls int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	c := NewCollector(SourceFunc(func(ctx context.Context) ([]smartctldata.OutputOrError, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		o := &smartctldata.Output{Device: smartctldata.Device{Name: "/dev/ada0"}, SerialNumber: "S1"}
+		return []smartctldata.OutputOrError{{O: o}}, nil
+	}))
+
+	var wg sync.WaitGroup
+	counts := make([]int, 3)
+	for i := range counts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			counts[i] = len(collect(c))
+		}(i)
+		if i == 0 {
+			<-started
+		}
+	}
+	// Let the other collections find the read in progress.
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("ReadAll called %d times by concurrent collections, want 1", calls)
+	}
+	for i, n := range counts {
+		if n == 0 || n != counts[0] {
+			t.Errorf("collection %d got %d metrics, collection 0 got %d", i, n, counts[0])
+		}
+	}
+
+	// A collection after the shared read is done reads again.
+	collect(c)
+	if calls != 2 {
+		t.Errorf("ReadAll called %d times, want 2", calls)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "timed out" }
+func (timeoutError) Timeout() bool { return true }
+
+// testOutputs are outputs of smartctl --json used by TestCollect.
+var testOutputs = []string{
+	// Replaced by the next output of the same device.
+	`{"json_format_version":[1,0],"smartctl":{"version":[7,1],"svn_revision":"5022","platform_info":"x86_64-linux","exit_status":0},
+	"device":{"name":"/dev/ada0","type":"atacam","protocol":"ATA"},"model_name":"Old","serial_number":"A1"}`,
+	`{"json_format_version":[1,0],"smartctl":{"version":[7,1],"svn_revision":"5022","platform_info":"x86_64-linux","exit_status":64,
+		"messages":[{"string":"Warning: some warning","severity":"warning"}]},
+	"device":{"name":"/dev/ada0","type":"atacam","protocol":"ATA"},"model_family":"Family","model_name":"Model","serial_number":"A1",
+	"firmware_version":"FW1","in_smartctl_database":true,"ata_version":{"string":"ACS-2"},"sata_version":{"string":"SATA 3.0"},
+	"ata_smart_attributes":{"table":[
+		{"id":5,"name":"Reallocated_Sector_Ct","value":100,"worst":100,"thresh":10,"flags":{"prefailure":true},"raw":{"value":0,"string":"0"}},
+		{"id":194,"name":"Temperature_Celsius","value":112,"worst":100,"thresh":0,"raw":{"value":201863462947,"string":"35 (Min/Max 18/47)"}}]}}`,
+	// Skipped because of power mode and never read before.
+	`{"json_format_version":[1,0],"smartctl":{"version":[7,1],"svn_revision":"5022","platform_info":"x86_64-linux","exit_status":2,
+		"messages":[{"string":"Device is in STANDBY mode, exit(2)","severity":"information"}]},
+	"device":{"name":"/dev/ada1","type":"atacam","protocol":"ATA"}}`,
+	// Exit status is not known.
+	`{"json_format_version":[1,0],"smartctl":{"version":[6,6]},"device":{"name":"/dev/ada3","type":"atacam"},"serial_number":"D1"}`,
+}
+
+// TestCollect checks that a repeated device is exported once with its last
+// output, a device skipped in standby without earlier data only exports its
+// power mode and messages, exit status bits are only exported when the exit
+// status is known and raw min and max only for attributes which have them.
+func TestCollect(t *testing.T) {
+	var res []smartctldata.OutputOrError
+	for _, s := range testOutputs {
+		var o smartctldata.Output
+		if err := smartctldata.UnmarshalOutput([]byte(s), &o); err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, smartctldata.OutputOrError{O: &o})
+	}
+	res = append(res, smartctldata.OutputOrError{
+		O:   &smartctldata.Output{Device: smartctldata.Device{Name: "/dev/ada2", Type: "atacam"}},
+		Err: timeoutError{},
+	})
+	c := NewCollector(SourceFunc(func(ctx context.Context) ([]smartctldata.OutputOrError, error) {
+		return res, nil
+	}))
+	const want = `
+# HELP smartctl_version_info Version of smartctl which produced SMART data, always 1.
+# TYPE smartctl_version_info gauge
+smartctl_version_info{platform_info="",svn_revision="",version="6.6"} 1
+smartctl_version_info{platform_info="x86_64-linux",svn_revision="5022",version="7.1"} 1
+# HELP smart_device_info Device identity reported by smartctl, always 1.
+# TYPE smart_device_info gauge
+smart_device_info{ata_version="",device_model_family="",device_model_name="",device_name="/dev/ada3",device_protocol="",device_serial_number="D1",device_type="atacam",firmware_version="",in_smartctl_database="no",sata_version="",wwn=""} 1
+smart_device_info{ata_version="ACS-2",device_model_family="Family",device_model_name="Model",device_name="/dev/ada0",device_protocol="ATA",device_serial_number="A1",device_type="atacam",firmware_version="FW1",in_smartctl_database="yes",sata_version="SATA 3.0",wwn=""} 1
+# HELP smart_device_smartctl_exit_bit Bits of smartctl exit status, see EXIT STATUS in smartctl(8). Not exported if exit status is not known or device was skipped because of low power mode.
+# TYPE smart_device_smartctl_exit_bit gauge
+smart_device_smartctl_exit_bit{bit="below_threshold_in_past",device_name="/dev/ada0",device_serial_number="A1"} 0
+smart_device_smartctl_exit_bit{bit="command_failed",device_name="/dev/ada0",device_serial_number="A1"} 0
+smart_device_smartctl_exit_bit{bit="command_line_error",device_name="/dev/ada0",device_serial_number="A1"} 0
+smart_device_smartctl_exit_bit{bit="device_open_failed",device_name="/dev/ada0",device_serial_number="A1"} 0
+smart_device_smartctl_exit_bit{bit="disk_failing",device_name="/dev/ada0",device_serial_number="A1"} 0
+smart_device_smartctl_exit_bit{bit="error_log_has_errors",device_name="/dev/ada0",device_serial_number="A1"} 1
+smart_device_smartctl_exit_bit{bit="prefail_below_threshold",device_name="/dev/ada0",device_serial_number="A1"} 0
+smart_device_smartctl_exit_bit{bit="self_test_log_has_errors",device_name="/dev/ada0",device_serial_number="A1"} 0
+# HELP smart_device_smartctl_messages Number of messages printed by smartctl while reading device, by severity.
+# TYPE smart_device_smartctl_messages gauge
+smart_device_smartctl_messages{device_name="/dev/ada0",device_serial_number="A1",severity="error"} 0
+smart_device_smartctl_messages{device_name="/dev/ada0",device_serial_number="A1",severity="information"} 0
+smart_device_smartctl_messages{device_name="/dev/ada0",device_serial_number="A1",severity="warning"} 1
+smart_device_smartctl_messages{device_name="/dev/ada1",device_serial_number="",severity="error"} 0
+smart_device_smartctl_messages{device_name="/dev/ada1",device_serial_number="",severity="information"} 1
+smart_device_smartctl_messages{device_name="/dev/ada1",device_serial_number="",severity="warning"} 0
+smart_device_smartctl_messages{device_name="/dev/ada3",device_serial_number="D1",severity="error"} 0
+smart_device_smartctl_messages{device_name="/dev/ada3",device_serial_number="D1",severity="information"} 0
+smart_device_smartctl_messages{device_name="/dev/ada3",device_serial_number="D1",severity="warning"} 0
+# HELP smart_device_power_mode Power mode of device. Last known SMART data are exported if device was not read because it was in a low power mode.
+# TYPE smart_device_power_mode gauge
+smart_device_power_mode{device_name="/dev/ada0",device_serial_number="A1",mode="active_or_idle"} 1
+smart_device_power_mode{device_name="/dev/ada1",device_serial_number="",mode="standby"} 1
+smart_device_power_mode{device_name="/dev/ada3",device_serial_number="D1",mode="active_or_idle"} 1
+# HELP smart_device_collection_timeout 1 if smartctl was killed because it did not read SMART data from device in time.
+# TYPE smart_device_collection_timeout gauge
+smart_device_collection_timeout{device_name="/dev/ada0",device_type="atacam"} 0
+smart_device_collection_timeout{device_name="/dev/ada1",device_type="atacam"} 0
+smart_device_collection_timeout{device_name="/dev/ada2",device_type="atacam"} 1
+smart_device_collection_timeout{device_name="/dev/ada3",device_type="atacam"} 0
+# HELP smart_device_ata_attribute_raw_value_min Minimum of raw value for attributes which record it, e.g. temperature.
+# TYPE smart_device_ata_attribute_raw_value_min gauge
+smart_device_ata_attribute_raw_value_min{device_name="/dev/ada0",device_serial_number="A1",id="194",name="temperature_celsius",prefailure="no"} 18
+# HELP smart_device_ata_attribute_raw_value_max Maximum of raw value for attributes which record it, e.g. temperature.
+# TYPE smart_device_ata_attribute_raw_value_max gauge
+smart_device_ata_attribute_raw_value_max{device_name="/dev/ada0",device_serial_number="A1",id="194",name="temperature_celsius",prefailure="no"} 47
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want),
+		"smartctl_version_info",
+		"smart_device_info",
+		"smart_device_smartctl_exit_bit",
+		"smart_device_smartctl_messages",
+		"smart_device_power_mode",
+		"smart_device_collection_timeout",
+		"smart_device_ata_attribute_raw_value_min",
+		"smart_device_ata_attribute_raw_value_max",
+	); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,119 @@
+package smartctlrun
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/badrpc/smartctl2prom/smartctldata"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func cacheKey(d smartctldata.Device) string {
+	return d.Name + " " + d.Type
+}
+
+func (r *Runner) cacheFile(d smartctldata.Device) string {
+	return filepath.Join(r.CacheDir, unsafeFileChars.ReplaceAllString(cacheKey(d), "_")+".json")
+}
+
+// sameDisk reports whether cached output c may be used in place of output o
+// of a skipped device. smartctl skips a disk in low power mode before
+// identifying it, so o normally has no serial number. If it has one, it must
+// match the cached one.
+func sameDisk(c, o *smartctldata.Output) bool {
+	return o.SerialNumber == "" || o.SerialNumber == c.SerialNumber
+}
+
+// forgetMissing drops the last known outputs of devices not in the list.
+// A disk replaced in between scans may have a different serial number and
+// must not be reported with SMART data of the previous disk even when it is
+// skipped by NoCheck.
+func (r *Runner) forgetMissing(devices []smartctldata.Device) {
+	keep := make(map[string]bool)
+	for _, d := range devices {
+		keep[cacheKey(d)] = true
+		keep[r.cacheFile(d)] = true
+	}
+	r.mu.Lock()
+	for k := range r.last {
+		if !keep[k] {
+			delete(r.last, k)
+		}
+	}
+	r.mu.Unlock()
+	if r.CacheDir == "" {
+		return
+	}
+
+	files, err := filepath.Glob(filepath.Join(r.CacheDir, "*.json"))
+	if err != nil {
+		log.Printf("smartctlrun: %v", err)
+		return
+	}
+	for _, f := range files {
+		if keep[f] {
+			continue
+		}
+		if err := os.Remove(f); err != nil {
+			log.Printf("smartctlrun: %v", err)
+		}
+	}
+}
+
+// lastOutput returns the last output saved by saveOutput for device d or
+// nil if there is none.
+func (r *Runner) lastOutput(d smartctldata.Device) *smartctldata.Output {
+	r.mu.Lock()
+	o := r.last[cacheKey(d)]
+	r.mu.Unlock()
+	if o != nil || r.CacheDir == "" {
+		return o
+	}
+
+	b, err := ioutil.ReadFile(r.cacheFile(d))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("smartctlrun: %v", err)
+		}
+		return nil
+	}
+	o = &smartctldata.Output{}
+	if err := json.Unmarshal(b, o); err != nil {
+		log.Printf("smartctlrun: %s: %v", r.cacheFile(d), err)
+		return nil
+	}
+	return o
+}
+
+func (r *Runner) saveOutput(d smartctldata.Device, o *smartctldata.Output) {
+	r.mu.Lock()
+	if r.last == nil {
+		r.last = make(map[string]*smartctldata.Output)
+	}
+	r.last[cacheKey(d)] = o
+	r.mu.Unlock()
+	if r.CacheDir == "" {
+		return
+	}
+
+	b, err := json.Marshal(o)
+	if err != nil {
+		log.Printf("smartctlrun: %v", err)
+		return
+	}
+	// Write to a temporary file and rename to never leave a partially
+	// written file behind.
+	f := r.cacheFile(d)
+	if err := ioutil.WriteFile(f+".tmp", b, 0644); err != nil {
+		log.Printf("smartctlrun: %v", err)
+		return
+	}
+	if err := os.Rename(f+".tmp", f); err != nil {
+		log.Printf("smartctlrun: %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package smartctlrun
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// startProcessGroup makes cmd start in a new process group, so that
+// killProcessGroup also kills processes started by cmd, e.g. when smartctl is
+// a wrapper script or is run with sudo.
+func startProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
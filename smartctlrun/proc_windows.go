@@ -0,0 +1,9 @@
+package smartctlrun
+
+import "os/exec"
+
+func startProcessGroup(cmd *exec.Cmd) {}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
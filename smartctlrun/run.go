@@ -0,0 +1,226 @@
+// Package smartctlrun runs smartctl utility to discover devices and read
+// their SMART data.
+package smartctlrun
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/badrpc/smartctl2prom/smartctldata"
+)
+
+// Runner runs smartctl and decodes its JSON output.
+type Runner struct {
+	// SmartCtl is a path to smartctl binary. If empty, smartctl is looked
+	// up in PATH.
+	SmartCtl string
+	// Workers is the number of devices read concurrently by ReadAll. Devices
+	// are read one at a time if Workers is less than 1.
+	Workers int
+	// Timeout limits time smartctl is allowed to spend reading a single
+	// device. smartctl is killed when the timeout expires. Zero means no
+	// timeout.
+	Timeout time.Duration
+	// NoCheck is passed to smartctl as -n option to skip devices in low
+	// power modes, e.g. "standby". SMART data of a skipped device are
+	// replaced with the last known data (see Read).
+	NoCheck string
+	// CacheDir is a directory to keep the last known SMART data of devices
+	// in between runs. Data are only kept in memory if CacheDir is empty.
+	// ReadAll removes *.json files of devices it did not find from CacheDir,
+	// so the directory must not be shared.
+	CacheDir string
+
+	mu   sync.Mutex
+	last map[string]*smartctldata.Output
+}
+
+// TimeoutError is returned when smartctl did not finish reading a device
+// within Runner.Timeout.
+type TimeoutError struct {
+	Device smartctldata.Device
+	After  time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("smartctlrun: reading %s timed out after %v", e.Device.Name, e.After)
+}
+
+// Timeout reports whether the error is a timeout. It is always true.
+func (e *TimeoutError) Timeout() bool { return true }
+
+// Scan lists devices available for SMART queries with smartctl --scan-open.
+// --scan-open opens every device, so Timeout applies to it as well.
+func (r *Runner) Scan(ctx context.Context) ([]smartctldata.Device, error) {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	var so smartctldata.ScanOutput
+	unmarshal := func(b []byte) error { return json.Unmarshal(b, &so) }
+	if _, err := r.run(ctx, unmarshal, "--scan-open", "--json"); err != nil {
+		return nil, err
+	}
+	return so.Devices, nil
+}
+
+// Read reads SMART data from a device with smartctl -a. Exit status of
+// smartctl is stored in ExitStatus of the returned output. If Timeout is set
+// and expires, a *TimeoutError is returned.
+//
+// If smartctl skipped the device because of NoCheck, the last known data of
+// the device are returned with SmartCtl replaced by the current invocation,
+// so that PowerMode of the returned output reports the current power mode.
+// Only Device and SmartCtl are set if the device was never read or if the
+// skipped device reports a serial number different from the cached one.
+// ReadAll forgets the last known data of devices missing from a scan.
+func (r *Runner) Read(ctx context.Context, d smartctldata.Device) (*smartctldata.Output, error) {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	args := []string{"-a", "--json"}
+	if r.NoCheck != "" {
+		args = append(args, "-n", r.NoCheck)
+	}
+	args = append(args, d.Name)
+	if d.Type != "" {
+		args = append(args, "-d", d.Type)
+	}
+	var o smartctldata.Output
+	unmarshal := func(b []byte) error { return smartctldata.UnmarshalOutput(b, &o) }
+	status, err := r.run(ctx, unmarshal, args...)
+	if ctx.Err() == context.DeadlineExceeded && r.Timeout > 0 {
+		return nil, &TimeoutError{Device: d, After: r.Timeout}
+	}
+	if err != nil {
+		return nil, err
+	}
+	o.SmartCtl.ExitStatus = &status
+	if o.Device.Name == "" {
+		o.Device = d
+	}
+
+	if o.PowerMode() != "" {
+		if last := r.lastOutput(d); last != nil && sameDisk(last, &o) {
+			cached := *last
+			cached.SmartCtl = o.SmartCtl
+			return &cached, nil
+		}
+		return &o, nil
+	}
+	if status&0x3 == 0 {
+		// Bits 0 and 1 of exit status are set when smartctl could not
+		// read the device at all.
+		r.saveOutput(d, &o)
+	}
+	return &o, nil
+}
+
+// ReadAll discovers devices with Scan and reads SMART data of each of them
+// using up to Workers concurrent smartctl processes. Results are returned in
+// the order devices were discovered. Errors reading individual devices
+// (including timeouts) are reported in the returned slice and do not
+// prevent other devices from being read. For a *TimeoutError O is set and
+// only holds Device.
+//
+// ReadAll implements smartctlprom.Source.
+func (r *Runner) ReadAll(ctx context.Context) ([]smartctldata.OutputOrError, error) {
+	devices, err := r.Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if r.NoCheck != "" {
+		r.forgetMissing(devices)
+	}
+
+	workers := r.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(devices) {
+		workers = len(devices)
+	}
+
+	res := make([]smartctldata.OutputOrError, len(devices))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				o, err := r.Read(ctx, devices[i])
+				if _, ok := err.(*TimeoutError); ok {
+					// Let consumers know which device timed out.
+					o = &smartctldata.Output{Device: devices[i]}
+				}
+				res[i] = smartctldata.OutputOrError{O: o, Err: err}
+			}
+		}()
+	}
+	for i := range devices {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	return res, nil
+}
+
+// killWait is how long run waits for smartctl to exit after it was killed.
+const killWait = time.Second
+
+// run executes smartctl with args and decodes its JSON output with unmarshal.
+// smartctl and processes it started are killed when ctx is done.
+// smartctl reports problems with the device in the bits of non-zero exit
+// status while still producing valid output, so only failure to produce
+// decodable output is an error.
+func (r *Runner) run(ctx context.Context, unmarshal func([]byte) error, args ...string) (int, error) {
+	path := r.SmartCtl
+	if path == "" {
+		path = "smartctl"
+	}
+	cmd := exec.Command(path, args...)
+	startProcessGroup(cmd)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("smartctlrun: %s %s: %v", path, strings.Join(args, " "), err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		killProcessGroup(cmd)
+		// Processes which left the group may still hold stdout open
+		// and Wait would not return until they exit.
+		select {
+		case <-done:
+		case <-time.After(killWait):
+		}
+		return 0, fmt.Errorf("smartctlrun: %s %s: %v", path, strings.Join(args, " "), ctx.Err())
+	}
+	out := stdout.Bytes()
+	status := 0
+	if ee, ok := err.(*exec.ExitError); ok {
+		status = ee.ExitCode()
+	} else if err != nil {
+		return 0, fmt.Errorf("smartctlrun: %s %s: %v", path, strings.Join(args, " "), err)
+	}
+	if err := unmarshal(out); err != nil {
+		return status, fmt.Errorf("smartctlrun: %s %s: exit status %d: cannot decode output: %v %s", path, strings.Join(args, " "), status, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return status, nil
+}
@@ -0,0 +1,216 @@
+package smartctlrun
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/badrpc/smartctl2prom/smartctldata"
+)
+
+// fakeSmartCtl writes a shell script standing in for smartctl to a temporary
+// directory and returns the directory. The script is called smartctl, $dev is
+// set to the device argument and $dir to the directory.
+func fakeSmartCtl(t *testing.T, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake smartctl is a shell script")
+	}
+	dir, err := ioutil.TempDir("", "smartctlrun")
+	if err != nil {
+		t.Fatal(err)
+	}
+	script = "#!/bin/sh\n" +
+		"dir='" + dir + "'\n" +
+		"dev=''; for a in \"$@\"; do case \"$a\" in /dev/*) dev=$a;; esac; done\n" +
+		script
+	if err := ioutil.WriteFile(filepath.Join(dir, "smartctl"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+const scanScript = `
+if [ "$1" = --scan-open ]; then
+	echo '{"json_format_version":[1,0],"devices":[{"name":"/dev/ada0","type":"atacam"},{"name":"/dev/nvme0","type":"nvme"}]}'
+	exit 0
+fi
+`
+
+// deviceScript reports device $dev in standby if file $dir/standby exists
+// and its serial number is the content of $dir/serial otherwise.
+const deviceScript = `
+if [ -e "$dir/standby" ]; then
+	echo '{"json_format_version":[1,0],"smartctl":{"messages":[{"string":"Device is in STANDBY mode, exit(2)","severity":"information"}]},"serial_number":"'$(cat "$dir/standby")'"}'
+	exit 2
+fi
+echo '{"json_format_version":[1,0],"device":{"name":"'$dev'"},"model_name":"Fake","serial_number":"'$(cat "$dir/serial")'"}'
+exit 4
+`
+
+func TestScan(t *testing.T) {
+	dir := fakeSmartCtl(t, scanScript)
+	defer os.RemoveAll(dir)
+	r := &Runner{SmartCtl: filepath.Join(dir, "smartctl")}
+
+	devices, err := r.Scan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []smartctldata.Device{{Name: "/dev/ada0", Type: "atacam"}, {Name: "/dev/nvme0", Type: "nvme"}}
+	if len(devices) != len(want) {
+		t.Fatalf("Scan() = %+v, want %+v", devices, want)
+	}
+	for i := range want {
+		if devices[i].Name != want[i].Name || devices[i].Type != want[i].Type {
+			t.Errorf("Scan()[%d] = %+v, want %+v", i, devices[i], want[i])
+		}
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	dir := fakeSmartCtl(t, scanScript+deviceScript)
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "serial"), []byte("S1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := &Runner{SmartCtl: filepath.Join(dir, "smartctl"), Workers: 2}
+
+	res, err := r.ReadAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("ReadAll() returned %d outputs, want 2", len(res))
+	}
+	for i, name := range []string{"/dev/ada0", "/dev/nvme0"} {
+		if res[i].Err != nil {
+			t.Errorf("ReadAll()[%d]: %v", i, res[i].Err)
+			continue
+		}
+		o := res[i].O
+		if o.Device.Name != name || o.SerialNumber != "S1" {
+			t.Errorf("ReadAll()[%d] read device %q serial number %q, want %q S1", i, o.Device.Name, o.SerialNumber, name)
+		}
+		if s := o.SmartCtl.ExitStatus; s == nil || *s != 4 {
+			t.Errorf("ReadAll()[%d].SmartCtl.ExitStatus = %v, want 4", i, s)
+		}
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		script string
+	}{
+		{"exec", "exec sleep 10\n"},
+		// A child process keeps stdout open after smartctl is killed.
+		{"child", "sleep 10\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := fakeSmartCtl(t, tc.script)
+			defer os.RemoveAll(dir)
+			r := &Runner{SmartCtl: filepath.Join(dir, "smartctl"), Timeout: 100 * time.Millisecond}
+
+			start := time.Now()
+			_, err := r.Read(context.Background(), smartctldata.Device{Name: "/dev/ada0"})
+			if _, ok := err.(*TimeoutError); !ok {
+				t.Errorf("Read() error = %v, want *TimeoutError", err)
+			}
+			if d := time.Since(start); d > 5*time.Second {
+				t.Errorf("Read() returned after %v", d)
+			}
+		})
+	}
+}
+
+func TestReadStandby(t *testing.T) {
+	dir := fakeSmartCtl(t, scanScript+deviceScript)
+	defer os.RemoveAll(dir)
+	cacheDir := filepath.Join(dir, "cache")
+	if err := os.Mkdir(cacheDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	newRunner := func() *Runner {
+		return &Runner{SmartCtl: filepath.Join(dir, "smartctl"), NoCheck: "standby", CacheDir: cacheDir}
+	}
+	d := smartctldata.Device{Name: "/dev/ada0", Type: "atacam"}
+	ctx := context.Background()
+
+	write("serial", "S1")
+	if _, err := newRunner().Read(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		// standby is the serial number reported by the skipped device.
+		standby string
+		serial  string
+	}{
+		{"", "S1"},
+		{"S1", "S1"},
+		{"S2", "S2"},
+	} {
+		write("standby", tc.standby)
+		// A new runner only finds the cached output in CacheDir.
+		o, err := newRunner().Read(ctx, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o.PowerMode() != "standby" {
+			t.Errorf("standby %q: PowerMode() = %q, want standby", tc.standby, o.PowerMode())
+		}
+		if s := o.SmartCtl.ExitStatus; s == nil || *s != 2 {
+			t.Errorf("standby %q: ExitStatus = %v, want 2", tc.standby, s)
+		}
+		if o.SerialNumber != tc.serial {
+			t.Errorf("standby %q: SerialNumber = %q, want %q", tc.standby, o.SerialNumber, tc.serial)
+		}
+		wantModel := ""
+		if tc.serial == "S1" {
+			wantModel = "Fake"
+		}
+		if o.ModelName != wantModel {
+			t.Errorf("standby %q: ModelName = %q, want %q", tc.standby, o.ModelName, wantModel)
+		}
+	}
+}
+
+func TestReadAllForgetsMissingDevices(t *testing.T) {
+	dir := fakeSmartCtl(t, scanScript+deviceScript)
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "serial"), []byte("S1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cacheDir := filepath.Join(dir, "cache")
+	if err := os.Mkdir(cacheDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	r := &Runner{SmartCtl: filepath.Join(dir, "smartctl"), NoCheck: "standby", CacheDir: cacheDir}
+	gone := smartctldata.Device{Name: "/dev/ada1", Type: "atacam"}
+	if _, err := r.Read(context.Background(), gone); err != nil {
+		t.Fatal(err)
+	}
+	if r.lastOutput(gone) == nil {
+		t.Fatalf("output of %s was not cached", gone.Name)
+	}
+
+	if _, err := r.ReadAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if r.lastOutput(gone) != nil {
+		t.Errorf("output of %s is still cached after it was not found by scan", gone.Name)
+	}
+	if r.lastOutput(smartctldata.Device{Name: "/dev/ada0", Type: "atacam"}) == nil {
+		t.Errorf("output of /dev/ada0 was not cached")
+	}
+}